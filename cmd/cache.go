@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the dependency caches of builds",
+	Long: `Manage the dependency caches of builds.
+The pip, npm, yarn, go module, go build and maven caches of each runtime are kept in docker.cacheDir (default is ~/.cache/bifrost).`,
+}
+
+// cacheCleanCmd represents the cache clean command
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean [runtime...]",
+	Short: "Remove the dependency caches of builds",
+	Long:  `Remove the dependency caches of the given runtimes (or all runtimes).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := functions.CleanCache(args); err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheCleanCmd)
+}
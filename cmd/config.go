@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/niranjan94/bifrost/config"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var showFunction string
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect your config",
+	Long:  `Inspect your config`,
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the merged and resolved config",
+	Long: `Print the merged and resolved config.
+With --function, prints the config of the function with defaults and stage overrides applied.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkReferences()
+		settings := config.All()
+		if showFunction != "" {
+			function, ok := config.GetStringMapSub("serverless.functions", true)[showFunction]
+			if !ok {
+				logrus.Errorf("function %s not found", showFunction)
+				os.Exit(1)
+			}
+			settings = function.AllSettings()
+		}
+		output, err := yaml.Marshal(settings)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		fmt.Print(string(output))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+	configShowCmd.Flags().StringVar(&showFunction, "function", "", "Function to show the config of")
+}
@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"github.com/niranjan94/bifrost/config"
 	"github.com/niranjan94/bifrost/provision/aws"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/niranjan94/bifrost/provision/aws/status"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"os"
 )
 
 // deployCmd represents the deploy command
@@ -11,10 +16,32 @@ var deployCmd = &cobra.Command{
 	Short: "Deploy your stack to the cloud",
 	Long:  `Deploy your stack to the cloud`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.Validate(); err != nil {
+			logErrors(err)
+			os.Exit(1)
+		}
+		checkReferences()
+		warnDrift()
 		aws.Provision()
 	},
 }
 
+// warnDrift warns about every difference between the config and the deployed stage, which the deploy may overwrite
+func warnDrift() {
+	differences, err := status.Drift(functions.GetFilters())
+	if err != nil {
+		logrus.Warn("could not check for drift: ", err)
+		return
+	}
+	for _, d := range differences {
+		// functions that are not deployed yet are about to be created
+		if d.Key == "function" {
+			continue
+		}
+		logrus.Warn("drift: ", d)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(deployCmd)
 
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/niranjan94/bifrost/provision/aws/status"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var driftOutput string
+
+// driftCmd represents the drift command
+var driftCmd = &cobra.Command{
+	Use:   "drift [function...]",
+	Short: "Compare your config with the deployed stage",
+	Long: `Compare the config of the given functions (or all functions) with their live lambda configuration, stage alias,
+API Gateway integrations and authorizers and Cognito triggers. Exits with a non-zero status if they differ.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		differences, err := status.Drift(args)
+		if err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+
+		switch driftOutput {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(differences); err != nil {
+				logrus.Error(err)
+				os.Exit(1)
+			}
+		case "table":
+			if len(differences) == 0 {
+				logrus.Info("no drift found")
+				break
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "FUNCTION\tSOURCE\tKEY\tDESIRED\tLIVE")
+			for _, d := range differences {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Function, d.Source, d.Key, d.Desired, d.Live)
+			}
+			_ = w.Flush()
+		default:
+			logrus.Errorf("invalid output format %s. expected table or json", driftOutput)
+			os.Exit(1)
+		}
+
+		if len(differences) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+	driftCmd.Flags().StringVarP(&driftOutput, "output", "o", "table", "Output format: table or json")
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/niranjan94/bifrost/provision/aws/status"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var infoOutput string
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:     "info [function...]",
+	Aliases: []string{"status"},
+	Short:   "Show the deployed state of the stage",
+	Long: `Show the deployed version, configuration and bindings of the given functions (or all functions) on the current stage.
+Bindings that differ from the config are highlighted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses, err := status.Functions(args)
+		if err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+
+		switch infoOutput {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(statuses); err != nil {
+				logrus.Error(err)
+				os.Exit(1)
+			}
+		case "table":
+			printStatusTable(statuses)
+		default:
+			logrus.Errorf("invalid output format %s. expected table or json", infoOutput)
+			os.Exit(1)
+		}
+	},
+}
+
+// highlight colors a value red unless colors are disabled.
+// Highlighted values go in the last column, as the color codes would throw off the alignment of the tabwriter.
+func highlight(value string) string {
+	if noColor {
+		return "! " + value
+	}
+	return fmt.Sprintf("\x1b[31m%s\x1b[0m", value)
+}
+
+// printStatusTable prints the functions and their bindings as tables
+func printStatusTable(statuses []*status.FunctionStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "FUNCTION\tSTAGE\tLATEST\tALIAS\tRUNTIME\tMEMORY\tTIMEOUT\tCODE SIZE\tLAST MODIFIED\tSTATUS")
+	for _, s := range statuses {
+		if !s.Deployed {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t\t\t\t\t\t\t\t%s\n", s.FunctionName, s.Stage, highlight("not deployed"))
+			continue
+		}
+		state := "ok"
+		switch {
+		case s.AliasVersion == "":
+			state = highlight("alias missing")
+		case s.HasDrift():
+			state = highlight("drift")
+		}
+		_, _ = fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%s\t%d MB\t%d s\t%.1f KB\t%s\t%s\n",
+			s.FunctionName, s.Stage, s.LatestVersion, s.AliasVersion, s.Runtime,
+			s.MemorySize, s.Timeout, float64(s.CodeSize)/1024, s.LastModified, state,
+		)
+	}
+	_ = w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "FUNCTION\tKIND\tTARGET\tURL\tSTATUS")
+	for _, s := range statuses {
+		for _, binding := range s.Bindings {
+			state := "ok"
+			if binding.Drift != "" {
+				state = highlight(binding.Drift)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.FunctionName, binding.Kind, binding.Target, binding.Url, state)
+		}
+	}
+	_ = w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+	infoCmd.Flags().StringVarP(&infoOutput, "output", "o", "table", "Output format: table or json")
+	infoCmd.Flags().BoolVar(&noColor, "no-color", false, "Do not colorize drift")
+}
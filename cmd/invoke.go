@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/niranjan94/bifrost/local"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	eventFile      string
+	payload        string
+	invocationType string
+	qualifier      string
+)
+
+// invokeCmd represents the invoke command
+var invokeCmd = &cobra.Command{
+	Use:   "invoke <function>",
+	Short: "Invoke a function",
+	Long: `Invoke a deployed function on its stage alias.
+The payload is read from --payload, from the file given by --event or from stdin with --event -.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed("payload") && cmd.Flags().Changed("event") {
+			return errors.New("--payload and --event cannot be used together")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		checkReferences()
+		event := []byte(payload)
+		if payload == "" {
+			var err error
+			if event, err = readEvent(eventFile); err != nil {
+				logrus.Error(err)
+				os.Exit(1)
+			}
+		}
+		if !json.Valid(event) {
+			logrus.Error("the payload is not valid JSON")
+			os.Exit(1)
+		}
+		if DryRun {
+			invocationType = functions.InvocationTypeDryRun
+		}
+
+		output, err := functions.Invoke(args[0], event, invocationType, qualifier)
+		if err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+
+		if output.LogResult != nil {
+			logs, err := base64.StdEncoding.DecodeString(*output.LogResult)
+			if err != nil {
+				logrus.Error(err)
+			}
+			for _, line := range strings.Split(strings.TrimSpace(string(logs)), "\n") {
+				logrus.Info(line)
+			}
+		}
+		logrus.Infof("status code %d", aws.Int64Value(output.StatusCode))
+		if len(output.Payload) > 0 {
+			fmt.Println(string(output.Payload))
+		}
+		if output.FunctionError != nil {
+			logrus.Errorf("function error: %s", *output.FunctionError)
+			os.Exit(1)
+		}
+	},
+}
+
+// invokeLocalCmd represents the invoke local command
+var invokeLocalCmd = &cobra.Command{
+	Use:   "local <function>",
+	Short: "Build and invoke a function locally",
+	Long: `Build and invoke a function locally within the docker image of its runtime.
+The function's environment, memory size and timeout are applied.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		checkReferences()
+		event, err := readEvent(eventFile)
+		if err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+
+		deploymentPackages := functions.BuildFunctions(args)
+		if len(deploymentPackages) == 0 {
+			logrus.Errorf("function %s not found", args[0])
+			os.Exit(1)
+		}
+
+		result, err := local.Invoke(deploymentPackages[0], event)
+		if err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+
+		for _, line := range result.Logs {
+			logrus.Info(line)
+		}
+		if report := result.Report; report != nil {
+			logrus.Infof(
+				"duration: %.2f ms, billed duration: %.0f ms, memory used: %d/%d MB",
+				report.Duration, report.BilledDuration, report.MaxMemoryUsed, report.MemorySize,
+			)
+		}
+		fmt.Println(result.Payload)
+		if result.FunctionError {
+			os.Exit(1)
+		}
+	},
+}
+
+// readEvent reads the event from the given file, or stdin if the file is "-". An empty object is used if no file is given.
+func readEvent(file string) ([]byte, error) {
+	switch file {
+	case "":
+		return []byte("{}"), nil
+	case "-":
+		return ioutil.ReadAll(os.Stdin)
+	default:
+		return ioutil.ReadFile(file)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(invokeCmd)
+	invokeCmd.Flags().StringVarP(&eventFile, "event", "e", "", "JSON file with the payload to invoke the function with (- for stdin)")
+	invokeCmd.Flags().StringVarP(&payload, "payload", "p", "", "Inline JSON payload to invoke the function with (cannot be used with --event)")
+	invokeCmd.Flags().StringVarP(&invocationType, "type", "t", functions.InvocationTypeSync, "Invocation type: sync, async or dry-run")
+	invokeCmd.Flags().StringVarP(&qualifier, "qualifier", "q", "", "Version or alias to invoke (default is the function's stage)")
+
+	invokeCmd.AddCommand(invokeLocalCmd)
+	invokeLocalCmd.Flags().StringVarP(&eventFile, "event", "e", "", "JSON file with the event to invoke the function with (- for stdin)")
+	invokeLocalCmd.Flags().BoolVar(&local.AWSCredentials, "aws-credentials", false, "Pass the host's AWS credentials to the function (default is local.awsCredentials)")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// logColors are the ANSI colors the prefixes of the functions cycle through
+var logColors = []int{36, 33, 35, 32, 34, 31}
+
+var logsOptions functions.LogsOptions
+var noColor bool
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs [function...]",
+	Short: "Print the CloudWatch logs of functions",
+	Long: `Print the CloudWatch logs of the given functions, or of all functions if none are given.
+The log group of a function is /aws/lambda/<prefix><name><suffix>. Set endpoints.logs to use a local CloudWatch Logs stand-in.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		width := 0
+		for _, name := range args {
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+		prefixes := map[string]string{}
+		prefix := func(name string) string {
+			if p, ok := prefixes[name]; ok {
+				return p
+			}
+			if len(name) > width {
+				width = len(name)
+			}
+			p := fmt.Sprintf("%-*s |", width, name)
+			if !noColor {
+				p = fmt.Sprintf("\x1b[%dm%s\x1b[0m", logColors[len(prefixes)%len(logColors)], p)
+			}
+			prefixes[name] = p
+			return p
+		}
+
+		if err := functions.Logs(args, logsOptions, func(event *functions.LogEvent) {
+			fmt.Printf(
+				"%s %s %s\n",
+				prefix(event.Function),
+				event.Timestamp.Format(time.RFC3339),
+				strings.TrimRight(event.Message, "\n"),
+			)
+		}); err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().BoolVarP(&logsOptions.Follow, "follow", "f", false, "Keep printing new log events")
+	logsCmd.Flags().DurationVar(&logsOptions.Since, "since", 10*time.Minute, "Print log events newer than this duration, e.g. 30m or 2h")
+	logsCmd.Flags().StringVar(&logsOptions.FilterPattern, "filter", "", "CloudWatch Logs filter pattern the log events must match")
+	logsCmd.Flags().StringVarP(&logsOptions.Qualifier, "qualifier", "q", "", "Print only the log events of a version, or of the versions an alias routes to")
+	logsCmd.Flags().BoolVar(&logsOptions.StageAlias, "alias", false, "Print only the log events of the versions the stage alias routes to")
+	logsCmd.Flags().BoolVar(&noColor, "no-color", false, "Do not colorize the function prefixes")
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var retainVersions int
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune [function...]",
+	Short: "Delete old versions of functions",
+	Long: `Delete the published versions of the given functions (or all functions) except for the most recent ones.
+Versions referenced by an alias are never deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if retainVersions == 0 {
+			retainVersions = config.GetInt("serverless.retainVersions")
+		}
+		if retainVersions < 1 {
+			logrus.Error("set the number of versions to retain with --retain or serverless.retainVersions")
+			os.Exit(1)
+		}
+		if err := functions.Prune(args, retainVersions); err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().IntVar(&retainVersions, "retain", 0, "Number of most recent versions to retain (default is serverless.retainVersions)")
+}
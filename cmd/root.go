@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"github.com/mitchellh/go-homedir"
 	"github.com/niranjan94/bifrost/config"
 	"github.com/niranjan94/bifrost/utils"
+	"github.com/niranjan94/bifrost/utils/docker"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 )
 
 var (
@@ -20,6 +25,7 @@ var (
 	functionOnly bool
 	filter string
 	verbose bool
+	buildMode string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -31,11 +37,54 @@ var rootCmd = &cobra.Command{
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The root context is cancelled on SIGINT or SIGTERM, and a second signal exits immediately.
 func Execute() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	utils.SetContext(ctx)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		logrus.Warn("interrupted. cleaning up. interrupt again to exit immediately")
+		cancel()
+		<-signals
+		docker.RemoveAll()
+		os.Exit(130)
+	}()
+
+	// errors caused by the interrupt end bifrost like the interrupt itself rather than with a stack trace
+	var exitOnce sync.Once
+	exitInterrupted := func() {
+		exitOnce.Do(func() {
+			logrus.Warn("interrupted. exiting")
+			docker.RemoveAll()
+			os.Exit(130)
+		})
+		// another goroutine is exiting
+		select {}
+	}
+	utils.SetInterruptHandler(exitInterrupted)
+
+	// containers must not outlive a panic, e.g. from utils.Must
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(error); ok && utils.IsInterrupted(err) {
+				exitInterrupted()
+			}
+			docker.RemoveAll()
+			panic(r)
+		}
+	}()
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	if ctx.Err() != nil {
+		os.Exit(130)
+	}
 }
 
 func init() {
@@ -48,6 +97,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&functionOnly, "functions-only", false, "Deploy only functions")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose mode")
 	rootCmd.PersistentFlags().StringVar(&filter, "only", "", "Deploy only specific resources")
+	rootCmd.PersistentFlags().StringVar(&buildMode, "build-mode", "", "Build in docker containers (docker) or on the host (native) (default is serverless.package.buildMode or docker)")
 
 	utils.Must(viper.BindPFlags(rootCmd.PersistentFlags()))
 }
@@ -82,9 +132,29 @@ func initConfig() {
 		logrus.Error("No config file found.")
 		os.Exit(1)
 	}
+	if err := config.LoadFiles(viper.GetString("stage")); err != nil {
+		logrus.Error(err)
+		os.Exit(1)
+	}
 	config.LoadDefaults()
 }
 
+// checkReferences exits if any reference in the config cannot be resolved.
+// Only the commands that use resolved values call it, as resolving every reference may call SSM and Secrets Manager.
+func checkReferences() {
+	if err := config.CheckReferences(); err != nil {
+		logErrors(err)
+		os.Exit(1)
+	}
+}
+
+// logErrors logs every line of the error separately
+func logErrors(err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		logrus.Error(line)
+	}
+}
+
 // initLogger initializes the logrus instance
 func initLogger() {
 	logrus.SetFormatter(&logrus.TextFormatter{
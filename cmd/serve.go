@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/niranjan94/bifrost/local"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/niranjan94/bifrost/utils/docker"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveHost string
+	servePort int
+	noWatch   bool
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve your API locally",
+	Long: `Serve every function bound to API Gateway on a local HTTP server.
+Requests are converted to API Gateway proxy events and the functions are invoked within warm docker containers
+of their runtimes. WebSocket connections are routed to the functions bound to WebSocket routes and
+a local @connections endpoint lets functions post back to clients. Functions are rebuilt when their sources change.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkReferences()
+		names := local.BoundFunctions()
+		if filters := functions.GetFilters(); len(filters) > 0 {
+			var filtered []string
+			for _, name := range names {
+				if utils.StringSliceContains(filters, name) {
+					filtered = append(filtered, name)
+				}
+			}
+			names = filtered
+		}
+		if len(names) == 0 {
+			logrus.Error("no functions are bound to API Gateway resources or routes")
+			os.Exit(1)
+		}
+
+		server, err := local.NewServer(functions.BuildFunctions(names))
+		if err != nil {
+			logrus.Error(err)
+			os.Exit(1)
+		}
+
+		if !noWatch {
+			if err := server.Watch(); err != nil {
+				logrus.Error(err)
+			}
+		}
+
+		shutdown := make(chan struct{})
+		go func() {
+			defer close(shutdown)
+			<-utils.Context().Done()
+			logrus.Info("shutting down")
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
+
+		if err := server.ListenAndServe(fmt.Sprintf("%s:%d", serveHost, servePort)); err != nil {
+			logrus.Error(err)
+			server.Shutdown(context.Background())
+			docker.RemoveAll()
+			os.Exit(1)
+		}
+		<-shutdown
+		// the shutdown stops the runtime containers, but containers may be left by a build or a failed stop
+		docker.RemoveAll()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveHost, "host", "127.0.0.1", "Host to listen on")
+	serveCmd.Flags().IntVarP(&servePort, "port", "p", 3000, "Port to listen on")
+	serveCmd.Flags().BoolVar(&noWatch, "no-watch", false, "Do not rebuild functions when their sources change")
+	serveCmd.Flags().BoolVar(&local.AWSCredentials, "aws-credentials", false, "Pass the host's AWS credentials to the functions (default is local.awsCredentials)")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/niranjan94/bifrost/config"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var jsonSchemaFile string
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate your config file",
+	Long: `Validate your config file against bifrost's schema.
+Unknown keys, wrong types and invalid values are reported along with their file and line number.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if jsonSchemaFile != "" {
+			output, err := json.MarshalIndent(config.JSONSchema(), "", "  ")
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			if err := ioutil.WriteFile(jsonSchemaFile, output, 0644); err != nil {
+				logrus.Fatal(err)
+			}
+			logrus.Info("wrote JSON Schema to ", jsonSchemaFile)
+			return
+		}
+		if err := config.Validate(); err != nil {
+			logErrors(err)
+			os.Exit(1)
+		}
+		logrus.Info("config is valid")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().StringVar(&jsonSchemaFile, "json-schema", "", "Write the JSON Schema of the config file to the given file")
+}
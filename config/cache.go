@@ -1,7 +1,6 @@
 package config
 
 import (
-	"github.com/niranjan94/bifrost/utils"
 	c "github.com/patrickmn/go-cache"
 	"time"
 )
@@ -14,7 +13,7 @@ func init() {
 
 // ToCache stores a value to cache at a key
 func ToCache(key string, value interface{}) {
-	utils.Must(cache.Add(key, value, c.NoExpiration))
+	cache.Set(key, value, c.NoExpiration)
 }
 
 // FromCache returns the cached value for a key
@@ -22,18 +21,17 @@ func FromCache(key string) (interface{}, bool) {
 	return cache.Get(key)
 }
 
-// MemoizedFn stores the result of the function in the cache and returns it on subsequent calls
+// MemoizedFn stores the result of the function in the cache and returns it on subsequent calls.
+// Results that used unresolved references are not stored, so that they are resolved again on the next call.
 func MemoizedFn(key string, keyPrefix string, fn func(string) interface{}) interface{} {
 	cacheKey := keyPrefix + ":" + key
 	if cachedValue, found := FromCache(cacheKey); found {
 		return *cachedValue.(*interface{})
 	}
+	previousUnresolvedCount := unresolvedCount
 	value := fn(key)
-	if stringValue, isString := value.(string); isString {
-		if isReference(stringValue) {
-			value = MemoizedFn(stringValue, keyPrefix, fn)
-		}
+	if unresolvedCount == previousUnresolvedCount {
+		ToCache(cacheKey, &value)
 	}
-	ToCache(cacheKey, &value)
 	return value
-}
\ No newline at end of file
+}
@@ -78,7 +78,7 @@ func IsSet(key string) bool {
 	return viper.IsSet(key)
 }
 
-// All merges all settings and returns them as a map[string]interface{}.
+// All merges all settings, resolves references in them and returns them as a map[string]interface{}.
 func All() map[string]interface{} {
-	return viper.AllSettings()
+	return cast.ToStringMap(resolveValue(viper.AllSettings(), ""))
 }
@@ -5,23 +5,9 @@ import (
 	"github.com/spf13/viper"
 )
 
-// LoadDefaults replaces the defaults with their resolved values so that they can be used in other parts directly
+// LoadDefaults sets the settings derived from flags and the default settings.
+// The defaults are kept as they are and resolved when read, so that escaped references stay literal.
 func LoadDefaults() {
-	stringMap := viper.GetStringMap("defaults")
-	newMap := map[string]interface{}{}
-	for k, v := range stringMap {
-		switch v.(type) {
-		case string:
-			newMap[k] = getResolvedValue("defaults." + k)
-		case map[string]string:
-			newMap[k] = getResolvedStringMapStringValue("defaults." + k)
-		case map[string]interface{}:
-			newMap[k] = getResolvedStringMapValue("defaults." + k)
-		default:
-			newMap[k] = v
-		}
-	}
-	viper.Set("defaults", newMap)
 	viper.Set("dryRun", viper.GetBool("dry-run"))
 	viper.Set("filter", viper.GetString("only"))
 	viper.SetDefault("region", "ap-southeast-1")
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnresolvedReferenceError describes a reference that could not be resolved and the key it was used at
+type UnresolvedReferenceError struct {
+	Key       string
+	Reference string
+	Err       error
+}
+
+func (e *UnresolvedReferenceError) Error() string {
+	return fmt.Sprintf("%s: could not resolve %s: %s", e.Key, e.Reference, e.Err)
+}
+
+// UnresolvedReferenceErrors is a list of all the references that could not be resolved
+type UnresolvedReferenceErrors []*UnresolvedReferenceError
+
+func (e UnresolvedReferenceErrors) Error() string {
+	lines := []string{fmt.Sprintf("%d unresolved reference(s) in config", len(e))}
+	for _, err := range e {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CycleError is returned when a reference (indirectly) refers to itself
+type CycleError struct {
+	Chain []string
+}
+
+func (e *CycleError) Error() string {
+	return "reference cycle " + strings.Join(e.Chain, " -> ")
+}
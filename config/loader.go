@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// configFiles holds every config file that has been loaded, in the order they were merged
+var configFiles []string
+
+// LoadFiles merges the files included by the config file and the stage overlay (eg. bifrost.prod.yaml) into the config.
+// Included files are deep-merged on top of the file including them and the stage overlay is merged on top of everything.
+func LoadFiles(stage string) error {
+	mainFile := viper.ConfigFileUsed()
+	configFiles = []string{mainFile}
+	if err := mergeIncludes(mainFile, viper.GetStringSlice("include")); err != nil {
+		return err
+	}
+	if stage == "" {
+		return nil
+	}
+	overlayFile := stageOverlayFile(mainFile, stage)
+	if _, err := os.Stat(overlayFile); os.IsNotExist(err) {
+		return nil
+	}
+	return mergeFile(overlayFile)
+}
+
+// ConfigFiles returns the config files that are currently loaded
+func ConfigFiles() []string {
+	if len(configFiles) == 0 {
+		return []string{viper.ConfigFileUsed()}
+	}
+	return configFiles
+}
+
+// stageOverlayFile returns the path of the overlay of a config file for a stage
+func stageOverlayFile(file string, stage string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + stage + ext
+}
+
+// mergeIncludes merges every file matching the include patterns.
+// Patterns are relative to the directory of the file including them.
+func mergeIncludes(file string, patterns []string) error {
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid include %s: %s", file, pattern, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("%s: included file %s not found", file, pattern)
+		}
+		for _, match := range matches {
+			if err := mergeFile(match); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeFile deep-merges a config file and the files it includes into the config.
+// Files that have already been loaded are skipped.
+// The file is merged as parsed, as viper's settings of a file split keys containing dots (eg. python3.8 or ghcr.io).
+func mergeFile(file string) error {
+	for _, loadedFile := range configFiles {
+		if loadedFile == file {
+			return nil
+		}
+	}
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file, err)
+	}
+	settings := map[string]interface{}{}
+	if err := yaml.Unmarshal(contents, &settings); err != nil {
+		return fmt.Errorf("%s: %s", file, err)
+	}
+	logrus.Debug("merging config file: ", file)
+	configFiles = append(configFiles, file)
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	return mergeIncludes(file, cast.ToStringSlice(settings["include"]))
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// MergeStrategy decides how a list in a function's config is combined with the same list in defaults
+type MergeStrategy string
+
+const (
+	// MergeReplace replaces the list in defaults with the function's list
+	MergeReplace MergeStrategy = "replace"
+	// MergeAppend appends the function's list to the list in defaults
+	MergeAppend MergeStrategy = "append"
+)
+
+// MergeStrategies returns the merge strategy of every list in a function's config that is not replaced.
+// Strategies are declared in the schema and can be overridden by `serverless.mergeStrategies`,
+// a map of (dotted) function config keys to strategies.
+func MergeStrategies() map[string]MergeStrategy {
+	strategies := map[string]MergeStrategy{}
+	collectMergeStrategies(objectField("", functionFields()), "", strategies)
+	for key, strategy := range viper.GetStringMapString("serverless.mergeStrategies") {
+		strategies[strings.ToLower(key)] = MergeStrategy(strings.ToLower(strategy))
+	}
+	return strategies
+}
+
+func collectMergeStrategies(field *Field, key string, strategies map[string]MergeStrategy) {
+	if field.MergeStrategy != "" {
+		strategies[strings.ToLower(key)] = field.MergeStrategy
+	}
+	for name, child := range field.Fields {
+		collectMergeStrategies(child, joinKey(key, name), strategies)
+	}
+}
+
+// MergeDefaults returns a new map with the function's config deep-merged on top of the defaults.
+// Maps are merged recursively, scalars in the function's config override the defaults and
+// lists are replaced or appended to according to MergeStrategies.
+// Neither defaults nor config are modified, so the result does not depend on other functions.
+func MergeDefaults(defaults map[string]interface{}, config map[string]interface{}) map[string]interface{} {
+	return mergeMaps(cast.ToStringMap(deepCopy(defaults)), cast.ToStringMap(deepCopy(config)), "", MergeStrategies())
+}
+
+// mergeMaps recursively merges src into dst and returns dst.
+// key is the dotted key of dst and strategies decide how lists are merged. Lists are replaced by default.
+func mergeMaps(dst map[string]interface{}, src map[string]interface{}, key string, strategies map[string]MergeStrategy) map[string]interface{} {
+	for k, srcValue := range src {
+		childKey := joinKey(key, strings.ToLower(k))
+		if srcMap, srcIsMap := toStringMap(srcValue); srcIsMap {
+			if dstMap, dstIsMap := toStringMap(dst[k]); dstIsMap {
+				dst[k] = mergeMaps(dstMap, srcMap, childKey, strategies)
+				continue
+			}
+		}
+		if strategies[childKey] == MergeAppend {
+			srcList, srcIsList := toList(srcValue)
+			dstList, dstIsList := toList(dst[k])
+			if srcIsList && dstIsList {
+				dst[k] = append(dstList, srcList...)
+				continue
+			}
+		}
+		dst[k] = srcValue
+	}
+	return dst
+}
+
+// deepCopy returns a copy of the value with all maps and lists copied recursively
+func deepCopy(value interface{}) interface{} {
+	if valueMap, isMap := toStringMap(value); isMap {
+		copied := make(map[string]interface{}, len(valueMap))
+		for k, v := range valueMap {
+			copied[k] = deepCopy(v)
+		}
+		return copied
+	}
+	if valueList, isList := toList(value); isList {
+		copied := make([]interface{}, len(valueList))
+		for idx, v := range valueList {
+			copied[idx] = deepCopy(v)
+		}
+		return copied
+	}
+	return value
+}
+
+// toStringMap converts a map value to map[string]interface{}. ok is false if the value is not a map.
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch value.(type) {
+	case map[string]interface{}, map[interface{}]interface{}, map[string]string:
+		return cast.ToStringMap(value), true
+	}
+	return nil, false
+}
+
+// toList converts a list value to []interface{}. ok is false if the value is not a list.
+func toList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		list := make([]interface{}, len(v))
+		for idx := range v {
+			list[idx] = v[idx]
+		}
+		return list, true
+	}
+	return nil, false
+}
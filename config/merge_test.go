@@ -0,0 +1,137 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestMergeDefaults(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategies map[string]string
+		defaults   map[string]interface{}
+		config     map[string]interface{}
+		want       map[string]interface{}
+	}{
+		{
+			name: "maps are merged recursively",
+			defaults: map[string]interface{}{
+				"environment": map[string]interface{}{"STAGE": "dev", "LOG_LEVEL": "info"},
+				"vpcconfig":   map[string]interface{}{"subnetids": []interface{}{"subnet-a"}},
+			},
+			config: map[string]interface{}{
+				"environment": map[string]interface{}{"LOG_LEVEL": "debug", "TABLE": "users"},
+			},
+			want: map[string]interface{}{
+				"environment": map[string]interface{}{"STAGE": "dev", "LOG_LEVEL": "debug", "TABLE": "users"},
+				"vpcconfig":   map[string]interface{}{"subnetids": []interface{}{"subnet-a"}},
+			},
+		},
+		{
+			name:     "scalars of the function override the defaults",
+			defaults: map[string]interface{}{"memorysize": 128, "timeout": 30, "runtime": "python3.8"},
+			config:   map[string]interface{}{"memorysize": 512, "runtime": "python3.9"},
+			want:     map[string]interface{}{"memorysize": 512, "timeout": 30, "runtime": "python3.9"},
+		},
+		{
+			name:     "maps replace scalars",
+			defaults: map[string]interface{}{"tracing": "Active"},
+			config:   map[string]interface{}{"tracing": map[string]interface{}{"mode": "PassThrough"}},
+			want:     map[string]interface{}{"tracing": map[string]interface{}{"mode": "PassThrough"}},
+		},
+		{
+			name: "lists are replaced by default",
+			defaults: map[string]interface{}{
+				"layers":    []interface{}{"common"},
+				"vpcconfig": map[string]interface{}{"securitygroupids": []interface{}{"sg-default"}},
+			},
+			config: map[string]interface{}{
+				"layers":    []interface{}{"numpy"},
+				"vpcconfig": map[string]interface{}{"securitygroupids": []interface{}{"sg-function"}},
+			},
+			want: map[string]interface{}{
+				"layers":    []interface{}{"numpy"},
+				"vpcconfig": map[string]interface{}{"securitygroupids": []interface{}{"sg-function"}},
+			},
+		},
+		{
+			name:       "mergeStrategies entries append lists",
+			strategies: map[string]string{"vpcConfig.securityGroupIds": "append", "layers": "append"},
+			defaults: map[string]interface{}{
+				"layers":    []interface{}{"common"},
+				"vpcconfig": map[string]interface{}{"securitygroupids": []interface{}{"sg-default"}},
+			},
+			config: map[string]interface{}{
+				"layers":    []string{"numpy"},
+				"vpcconfig": map[string]interface{}{"securitygroupids": []interface{}{"sg-function"}},
+			},
+			want: map[string]interface{}{
+				"layers":    []interface{}{"common", "numpy"},
+				"vpcconfig": map[string]interface{}{"securitygroupids": []interface{}{"sg-default", "sg-function"}},
+			},
+		},
+		{
+			name:       "replace entries replace lists",
+			strategies: map[string]string{"layers": "replace"},
+			defaults:   map[string]interface{}{"layers": []interface{}{"common"}},
+			config:     map[string]interface{}{"layers": []interface{}{"numpy"}},
+			want:       map[string]interface{}{"layers": []interface{}{"numpy"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			if test.strategies != nil {
+				viper.Set("serverless.mergeStrategies", test.strategies)
+			}
+
+			got := MergeDefaults(test.defaults, test.config)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("MergeDefaults() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeDefaultsDoesNotModifyDefaults(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("serverless.mergeStrategies", map[string]string{"layers": "append"})
+
+	defaults := map[string]interface{}{
+		"timeout":     30,
+		"layers":      []interface{}{"common"},
+		"environment": map[string]interface{}{"STAGE": "dev"},
+	}
+	functions := []map[string]interface{}{
+		{
+			"timeout":     60,
+			"layers":      []interface{}{"numpy"},
+			"environment": map[string]interface{}{"STAGE": "prod", "TABLE": "users"},
+		},
+		{},
+	}
+
+	var merged []map[string]interface{}
+	for _, config := range functions {
+		merged = append(merged, MergeDefaults(defaults, config))
+	}
+	// modifying a merged config must not leak into the defaults either
+	merged[0]["environment"].(map[string]interface{})["LEAKED"] = "yes"
+
+	want := map[string]interface{}{
+		"timeout":     30,
+		"layers":      []interface{}{"common"},
+		"environment": map[string]interface{}{"STAGE": "dev"},
+	}
+	if !reflect.DeepEqual(defaults, want) {
+		t.Errorf("defaults were modified to %v, want %v", defaults, want)
+	}
+	if !reflect.DeepEqual(merged[1], want) {
+		t.Errorf("the second function got %v, want the unmodified defaults %v", merged[1], want)
+	}
+}
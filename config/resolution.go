@@ -1,39 +1,302 @@
 package config
 
 import (
-	"github.com/spf13/cast"
-	"github.com/spf13/viper"
+	"errors"
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	referenceStart  = "${"
+	referenceEnd    = "}"
+	referenceEscape = "$${"
+)
+
+// referenceContentRegex matches the content of a reference, i.e. `type:key` with an optional `, 'default'`.
+// References prefixed with `?` (eg. `${?env:NAME}`) are optional and may resolve to an empty value.
+var referenceContentRegex = regexp.MustCompile(`^\s*(\?)?([^\s,]+)\s*(,\s*'(.*)')?\s*$`)
+
+var (
+	// resolutionStack holds the references currently being resolved and is used to detect cycles
+	resolutionStack []string
+	// unresolvedReferences holds every unresolved reference found so far, indexed by key and reference
+	unresolvedReferences = map[string]*UnresolvedReferenceError{}
+	// unresolvedCount is the number of times an unresolved reference has been used
+	unresolvedCount int
 )
 
-// varExpressionMatchRegex matches a valid reference string
-var varExpressionMatchRegex = regexp.MustCompile(`(?i)\${([a-z:.]+)(,(\s+)?'(.+?)')?}`)
+// unresolvedReference is the value a reference resolves to when it cannot be resolved
+type unresolvedReference struct {
+	err error
+}
 
-// getValueFromReference retrieves the value from config from a reference string
+// getValueFromReference retrieves the value for a reference string using the resolver registered for its type.
+// The reference must not contain any nested references.
+// An *unresolvedReference is returned if the reference could not be resolved.
 func getValueFromReference(reference string) interface{} {
-	matches := varExpressionMatchRegex.FindStringSubmatch(reference)
-	typeKeyPair := strings.Split(matches[1], ":")
-	defaultValue := matches[4]
-	if val := viper.GetString(typeKeyPair[1]); val != "" {
-		return val
+	for idx, ref := range resolutionStack {
+		if ref == reference {
+			chain := append(append([]string{}, resolutionStack[idx:]...), reference)
+			return &unresolvedReference{err: &CycleError{Chain: chain}}
+		}
+	}
+	resolutionStack = append(resolutionStack, reference)
+	defer func() {
+		resolutionStack = resolutionStack[:len(resolutionStack)-1]
+	}()
+
+	parsed, err := parseReference(strings.TrimSuffix(strings.TrimPrefix(reference, referenceStart), referenceEnd))
+	if err != nil {
+		return &unresolvedReference{err: err}
+	}
+	val, err := parsed.resolver.Resolve(parsed.key)
+	if err != nil && !parsed.hasDefault {
+		return &unresolvedReference{err: err}
+	}
+	if isEmptyValue(val) || err != nil {
+		if !parsed.hasDefault && !parsed.isOptional {
+			return &unresolvedReference{err: errors.New("no value found and no default given")}
+		}
+		return parsed.defaultValue
+	}
+	return val
+}
+
+// parsedReference is the content of a reference split into its parts
+type parsedReference struct {
+	referenceType string
+	key           string
+	resolver      Resolver
+	isOptional    bool
+	hasDefault    bool
+	defaultValue  string
+}
+
+// parseReference parses the content of a reference without nested references, i.e. `type:key` with an optional default
+func parseReference(content string) (*parsedReference, error) {
+	matches := referenceContentRegex.FindStringSubmatch(content)
+	if matches == nil {
+		return nil, errors.New("invalid reference. expected ${type:key}")
+	}
+	typeKeyPair := strings.SplitN(matches[2], ":", 2)
+	if len(typeKeyPair) != 2 {
+		return nil, errors.New("invalid reference. expected ${type:key}")
+	}
+	resolver, ok := GetResolver(typeKeyPair[0])
+	if !ok {
+		return nil, fmt.Errorf("unknown reference type %s", typeKeyPair[0])
+	}
+	return &parsedReference{
+		referenceType: strings.ToLower(typeKeyPair[0]),
+		key:           typeKeyPair[1],
+		resolver:      resolver,
+		isOptional:    matches[1] != "",
+		hasDefault:    matches[3] != "",
+		defaultValue:  matches[4],
+	}, nil
+}
+
+// isEmptyValue checks if a resolved value is nil or an empty string
+func isEmptyValue(value interface{}) bool {
+	stringValue, isString := value.(string)
+	return value == nil || (isString && stringValue == "")
+}
+
+// resolveReference returns the cached value of a reference or resolves it.
+// Unresolved references are not cached, so that a failure (eg. a cycle detected midway) is retried on the next use.
+func resolveReference(reference string) interface{} {
+	cacheKey := "getResolvedValue:" + reference
+	if cachedValue, found := FromCache(cacheKey); found {
+		return *cachedValue.(*interface{})
+	}
+	value := getValueFromReference(reference)
+	if _, unresolved := value.(*unresolvedReference); !unresolved {
+		ToCache(cacheKey, &value)
+	}
+	return value
+}
+
+// recordUnresolvedReference stores an unresolved reference along with the key it was used at
+func recordUnresolvedReference(key string, reference string, err error) {
+	unresolvedCount++
+	id := key + "|" + reference
+	if _, exists := unresolvedReferences[id]; !exists {
+		unresolvedReferences[id] = &UnresolvedReferenceError{
+			Key:       key,
+			Reference: reference,
+			Err:       err,
+		}
+	}
+}
+
+// CheckReferences resolves every value in the config and
+// returns an UnresolvedReferenceErrors listing every reference that could not be resolved
+func CheckReferences() error {
+	resolveValue(viper.AllSettings(), "")
+	if len(unresolvedReferences) == 0 {
+		return nil
+	}
+	var errs UnresolvedReferenceErrors
+	for _, err := range unresolvedReferences {
+		errs = append(errs, err)
 	}
-	return defaultValue
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].Key == errs[j].Key {
+			return errs[i].Reference < errs[j].Reference
+		}
+		return errs[i].Key < errs[j].Key
+	})
+	return errs
 }
 
-// isReference checks if the string value looks like a reference string
+// isReference checks if the string value contains a reference string
 func isReference(value string) bool {
-	return value != "" && strings.HasPrefix(value, "${") && varExpressionMatchRegex.MatchString(value)
+	return strings.Contains(value, referenceStart)
+}
+
+// isTypedReference checks if the content of a reference starts with a `type:` prefix.
+// `${...}` expressions without a type (eg. `${stageVariables.lambdaAlias}`) are left as is.
+func isTypedReference(content string) bool {
+	content = strings.TrimPrefix(strings.TrimSpace(content), "?")
+	idx := strings.Index(content, ":")
+	return idx > 0 && !strings.ContainsAny(content[:idx], " ,'")
+}
+
+// findReferenceEnd returns the index of the `}` closing the reference starting at start, taking nesting into account.
+// Quoted defaults (eg. `'a}b'`) may contain `}` and `${`. -1 is returned if the reference is not closed.
+func findReferenceEnd(value string, start int) int {
+	depth := 0
+	quoted := false
+	for idx := start; idx < len(value); idx++ {
+		switch {
+		case value[idx] == '\'' && depth > 0:
+			quoted = !quoted
+		case quoted:
+		case strings.HasPrefix(value[idx:], referenceStart):
+			depth++
+			idx++
+		case value[idx] == '}':
+			depth--
+			if depth == 0 {
+				return idx
+			}
+		}
+	}
+	return -1
+}
+
+// interpolate resolves every reference within the string value, including nested references.
+// If the value consists of a single reference, the resolved value is returned as is (which may not be a string).
+// `$${` is replaced by a literal `${`. Unresolved references are recorded against key.
+func interpolate(value string, key string) interface{} {
+	if !isReference(value) {
+		return value
+	}
+
+	var builder strings.Builder
+	var parts []interface{}
+
+	for idx := 0; idx < len(value); {
+		if strings.HasPrefix(value[idx:], referenceEscape) {
+			builder.WriteString(referenceStart)
+			idx += len(referenceEscape)
+			continue
+		}
+		if !strings.HasPrefix(value[idx:], referenceStart) {
+			builder.WriteByte(value[idx])
+			idx++
+			continue
+		}
+		end := findReferenceEnd(value, idx)
+		if end < 0 {
+			builder.WriteString(value[idx:])
+			break
+		}
+		content := cast.ToString(interpolate(value[idx+len(referenceStart):end], key))
+		if !isTypedReference(content) {
+			builder.WriteString(referenceStart + content + referenceEnd)
+		} else {
+			if builder.Len() > 0 {
+				parts = append(parts, builder.String())
+				builder.Reset()
+			}
+			reference := referenceStart + content + referenceEnd
+			resolved := resolveReference(reference)
+			if unresolved, ok := resolved.(*unresolvedReference); ok {
+				recordUnresolvedReference(key, reference, unresolved.err)
+				resolved = ""
+			}
+			parts = append(parts, resolved)
+		}
+		idx = end + 1
+	}
+	if builder.Len() > 0 || len(parts) == 0 {
+		parts = append(parts, builder.String())
+	}
+
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	var result strings.Builder
+	for _, part := range parts {
+		result.WriteString(cast.ToString(part))
+	}
+	return result.String()
+}
+
+// resolveValue returns a copy of the value at key with all references resolved.
+// Strings are interpolated, and maps and slices are resolved recursively.
+func resolveValue(value interface{}, key string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return interpolate(v, key)
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			resolved[k] = resolveValue(item, joinKey(key, k))
+		}
+		return resolved
+	case map[interface{}]interface{}:
+		return resolveValue(cast.ToStringMap(v), key)
+	case map[string]string:
+		resolved := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			resolved[k] = resolveValue(item, joinKey(key, k))
+		}
+		return resolved
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for idx, item := range v {
+			resolved[idx] = resolveValue(item, fmt.Sprintf("%s[%d]", key, idx))
+		}
+		return resolved
+	case []string:
+		resolved := make([]interface{}, len(v))
+		for idx, item := range v {
+			resolved[idx] = resolveValue(item, fmt.Sprintf("%s[%d]", key, idx))
+		}
+		return resolved
+	default:
+		return value
+	}
+}
+
+// joinKey joins a key to its parent key using a dot
+func joinKey(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
 }
 
 // getResolvedValue gets the value as an interface at a given key and also recursively resolves references as needed
 func getResolvedValue(key string) interface{} {
-	currentValue := viper.Get(key)
-	currentValueString, isString := currentValue.(string)
-	if !isString || !isReference(currentValueString) {
-		return currentValue
-	}
-	return MemoizedFn(currentValueString, "getResolvedValue", getValueFromReference)
+	return resolveValue(viper.Get(key), key)
 }
 
 // getResolvedStringMapStringValue gets the value at a given key as a map[string]string
@@ -42,15 +305,8 @@ func getResolvedStringMapStringValue(key string) interface{} {
 	return cast.ToStringMapString(getResolvedStringMapValue(key))
 }
 
-// getResolvedStringMapStringValue gets the value at a given key as a map of interfaces
+// getResolvedStringMapValue gets the value at a given key as a map of interfaces
 // and also recursively resolves references as needed
 func getResolvedStringMapValue(key string) interface{} {
-	mapValue := cast.ToStringMap(getResolvedValue(key))
-	for k, v := range mapValue {
-		stringValue, isString := v.(string)
-		if isString && isReference(stringValue) {
-			mapValue[k] = MemoizedFn(stringValue, "getResolvedValue", getValueFromReference)
-		}
-	}
-	return mapValue
+	return cast.ToStringMap(getResolvedValue(key))
 }
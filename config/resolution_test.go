@@ -0,0 +1,197 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// resetResolution clears the config, the resolved references and the unresolved references
+func resetResolution() {
+	viper.Reset()
+	cache.Flush()
+	resolutionStack = nil
+	unresolvedReferences = map[string]*UnresolvedReferenceError{}
+	unresolvedCount = 0
+}
+
+// unresolved returns the references that could not be resolved so far
+func unresolved() []string {
+	var references []string
+	for _, err := range unresolvedReferences {
+		references = append(references, err.Reference)
+	}
+	sort.Strings(references)
+	return references
+}
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("BIFROST_TEST_A", "a")
+	os.Setenv("BIFROST_TEST_KIND", "A")
+	defer os.Unsetenv("BIFROST_TEST_A")
+	defer os.Unsetenv("BIFROST_TEST_KIND")
+	RegisterResolver("test", ResolverFunc(func(key string) (interface{}, error) {
+		switch key {
+		case "number":
+			return 42, nil
+		case "list":
+			return []interface{}{"x", "y"}, nil
+		}
+		return nil, errors.New("not found")
+	}))
+	defer delete(resolvers, "test")
+
+	tests := []struct {
+		name           string
+		value          string
+		want           interface{}
+		wantUnresolved []string
+	}{
+		{name: "plain string", value: "hello", want: "hello"},
+		{name: "single reference", value: "${env:BIFROST_TEST_A}", want: "a"},
+		{name: "embedded reference", value: "pre-${env:BIFROST_TEST_A}-post", want: "pre-a-post"},
+		{name: "several references", value: "${env:BIFROST_TEST_A}${env:BIFROST_TEST_KIND}", want: "aA"},
+		{name: "nested reference", value: "${env:BIFROST_TEST_${env:BIFROST_TEST_KIND}}", want: "a"},
+		{name: "escaped reference", value: "$${env:BIFROST_TEST_A}", want: "${env:BIFROST_TEST_A}"},
+		{name: "escaped and resolved reference", value: "$${env:X}-${env:BIFROST_TEST_A}", want: "${env:X}-a"},
+		{name: "untyped expression", value: "${stageVariables.lambdaAlias}", want: "${stageVariables.lambdaAlias}"},
+		{name: "optional reference", value: "${?env:BIFROST_TEST_UNSET}", want: ""},
+		{name: "optional embedded reference", value: "a-${?env:BIFROST_TEST_UNSET}", want: "a-"},
+		{
+			name:           "missing reference",
+			value:          "${env:BIFROST_TEST_UNSET}",
+			want:           "",
+			wantUnresolved: []string{"${env:BIFROST_TEST_UNSET}"},
+		},
+		{name: "default", value: "${env:BIFROST_TEST_UNSET, 'b'}", want: "b"},
+		{name: "default containing a comma", value: "${env:BIFROST_TEST_UNSET, 'b,c'}", want: "b,c"},
+		{name: "default containing a brace", value: "${env:BIFROST_TEST_UNSET, 'b}c'}", want: "b}c"},
+		{name: "default containing a reference", value: "${env:BIFROST_TEST_UNSET, '${env:BIFROST_TEST_A}'}", want: "a"},
+		{name: "default of a set value", value: "${env:BIFROST_TEST_A, 'b'}", want: "a"},
+		{
+			name:           "unknown type",
+			value:          "${nope:key}",
+			want:           "",
+			wantUnresolved: []string{"${nope:key}"},
+		},
+		{name: "unclosed reference", value: "a-${env:BIFROST_TEST_A", want: "a-${env:BIFROST_TEST_A"},
+		{name: "non-string value", value: "${test:number}", want: 42},
+		{name: "list value", value: "${test:list}", want: []interface{}{"x", "y"}},
+		{name: "embedded non-string value", value: "n=${test:number}", want: "n=42"},
+		{
+			name:           "failing resolver",
+			value:          "${test:other}",
+			want:           "",
+			wantUnresolved: []string{"${test:other}"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetResolution()
+			defer resetResolution()
+
+			if got := interpolate(test.value, "key"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("interpolate(%q) = %#v, want %#v", test.value, got, test.want)
+			}
+			if got := unresolved(); !reflect.DeepEqual(got, test.wantUnresolved) {
+				t.Errorf("unresolved references = %v, want %v", got, test.wantUnresolved)
+			}
+		})
+	}
+}
+
+func TestFindReferenceEnd(t *testing.T) {
+	tests := []struct {
+		value string
+		start int
+		want  int
+	}{
+		{value: "${env:A}", want: 7},
+		{value: "a-${env:A}-b", start: 2, want: 9},
+		{value: "${env:${env:B}}", want: 14},
+		{value: "${env:A, 'a}b'}", want: 14},
+		{value: "${env:A, '${x'}", want: 14},
+		{value: "${env:A", want: -1},
+		{value: "${env:${env:B}", want: -1},
+	}
+	for _, test := range tests {
+		if got := findReferenceEnd(test.value, test.start); got != test.want {
+			t.Errorf("findReferenceEnd(%q, %d) = %d, want %d", test.value, test.start, got, test.want)
+		}
+	}
+}
+
+func TestIsTypedReference(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{content: "env:A", want: true},
+		{content: " ?env:A", want: true},
+		{content: "file:config.json:a.b", want: true},
+		{content: "stageVariables.lambdaAlias", want: false},
+		{content: ":A", want: false},
+		{content: "a, 'b:c'", want: false},
+	}
+	for _, test := range tests {
+		if got := isTypedReference(test.content); got != test.want {
+			t.Errorf("isTypedReference(%q) = %v, want %v", test.content, got, test.want)
+		}
+	}
+}
+
+func TestReferenceCycles(t *testing.T) {
+	resetResolution()
+	defer resetResolution()
+	viper.Set("a", "${self:b}")
+	viper.Set("b", "x-${self:a}")
+	viper.Set("c", "${self:d}")
+	viper.Set("d", "d")
+
+	if got := interpolate("${self:c}", "key"); got != "d" {
+		t.Errorf("interpolate(${self:c}) = %v, want d", got)
+	}
+	interpolate("${self:a}", "key")
+
+	var cycles [][]string
+	for _, err := range unresolvedReferences {
+		if cycleErr, ok := err.Err.(*CycleError); ok {
+			cycles = append(cycles, cycleErr.Chain)
+		}
+	}
+	want := [][]string{{"${self:a}", "${self:b}", "${self:a}"}}
+	if !reflect.DeepEqual(cycles, want) {
+		t.Errorf("cycles = %v, want %v", cycles, want)
+	}
+	if len(resolutionStack) != 0 {
+		t.Errorf("resolution stack was left at %v", resolutionStack)
+	}
+}
+
+func TestUnresolvedReferencesAreNotCached(t *testing.T) {
+	resetResolution()
+	defer resetResolution()
+	defer os.Unsetenv("BIFROST_TEST_LATE")
+	viper.Set("late", map[string]interface{}{"value": "${env:BIFROST_TEST_LATE}"})
+
+	if got := interpolate("${env:BIFROST_TEST_LATE}", "key"); got != "" {
+		t.Errorf("interpolate() = %v before the variable is set, want an empty value", got)
+	}
+	GetStringMap("late")
+	os.Setenv("BIFROST_TEST_LATE", "late")
+	if got := GetStringMap("late")["value"]; got != "late" {
+		t.Errorf("GetStringMap() = %v after the variable is set, want late", got)
+	}
+	if got := interpolate("${env:BIFROST_TEST_LATE}", "key"); got != "late" {
+		t.Errorf("interpolate() = %v after the variable is set, want late", got)
+	}
+	os.Setenv("BIFROST_TEST_LATE", "later")
+	if got := interpolate("${env:BIFROST_TEST_LATE}", "key"); got != "late" {
+		t.Errorf("interpolate() = %v, want the cached value late", got)
+	}
+}
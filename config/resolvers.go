@@ -0,0 +1,200 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Resolver resolves the key of a reference (the part after `type:`) to its value
+type Resolver interface {
+	Resolve(key string) (interface{}, error)
+}
+
+// ResolverFunc allows plain functions to be used as a Resolver
+type ResolverFunc func(key string) (interface{}, error)
+
+// Resolve calls fn(key)
+func (fn ResolverFunc) Resolve(key string) (interface{}, error) {
+	return fn(key)
+}
+
+var resolvers = map[string]Resolver{}
+
+func init() {
+	RegisterResolver("self", ResolverFunc(resolveSelf))
+	RegisterResolver("opt", ResolverFunc(resolveOpt))
+	RegisterResolver("env", ResolverFunc(resolveEnv))
+	RegisterResolver("file", ResolverFunc(resolveFile))
+	RegisterResolver("ssm", ResolverFunc(resolveSSM))
+	RegisterResolver("secret", ResolverFunc(resolveSecret))
+}
+
+// RegisterResolver registers a resolver for references of the given type.
+// An existing resolver for the same type is replaced.
+func RegisterResolver(referenceType string, resolver Resolver) {
+	resolvers[strings.ToLower(referenceType)] = resolver
+}
+
+// GetResolver returns the resolver registered for the given reference type
+func GetResolver(referenceType string) (Resolver, bool) {
+	resolver, ok := resolvers[strings.ToLower(referenceType)]
+	return resolver, ok
+}
+
+// resolveSelf resolves to the value at another key of the config file.
+// References within that value are resolved as well and an error is returned if any of them cannot be resolved.
+func resolveSelf(key string) (interface{}, error) {
+	previousUnresolvedCount := unresolvedCount
+	value := getResolvedValue(key)
+	if unresolvedCount > previousUnresolvedCount {
+		return nil, fmt.Errorf("%s refers to unresolved references", key)
+	}
+	return value, nil
+}
+
+// resolveOpt resolves to the value of a command line option
+func resolveOpt(key string) (interface{}, error) {
+	return viper.GetString(key), nil
+}
+
+// resolveEnv resolves to the value of an environment variable
+func resolveEnv(key string) (interface{}, error) {
+	return os.Getenv(key), nil
+}
+
+// resolveFile resolves to the contents of a file. The key is of the format `path[:selector]`.
+// The selector starts after the first colon of the key (ignoring a Windows drive) and may contain colons itself.
+// If a dotted selector is given, the file is parsed as JSON or YAML (based on its extension)
+// and the value at the selector is returned.
+func resolveFile(key string) (interface{}, error) {
+	filePath, selector := splitFileKey(key)
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if selector == "" {
+		return strings.TrimSpace(string(contents)), nil
+	}
+
+	var document interface{}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		err = json.Unmarshal(contents, &document)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &document)
+	default:
+		return nil, fmt.Errorf("cannot select %s from %s. only JSON and YAML files are supported", selector, filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return selectPath(document, selector)
+}
+
+// resolveSSM resolves to the (decrypted) value of an SSM Parameter Store parameter
+func resolveSSM(key string) (interface{}, error) {
+	ssmSvc := ssm.New(awsutils.GetSession())
+	output, err := ssmSvc.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(key),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+// resolveSecret resolves to the value of a Secrets Manager secret. The key is of the format `name[:jsonKey]`.
+// The name may be an ARN. If a json key is given, the secret string is parsed as JSON and the value at the key is returned.
+func resolveSecret(key string) (interface{}, error) {
+	name, selector := splitSecretKey(key)
+	secretsSvc := secretsmanager.New(awsutils.GetSession())
+	output, err := secretsSvc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	secretString := aws.StringValue(output.SecretString)
+	if selector == "" {
+		return secretString, nil
+	}
+	var document interface{}
+	if err := json.Unmarshal([]byte(secretString), &document); err != nil {
+		return nil, fmt.Errorf("secret %s is not valid JSON: %s", name, err)
+	}
+	return selectPath(document, selector)
+}
+
+// splitKey splits a key into the part before the first colon at or after offset and the part after it
+func splitKey(key string, offset int) (string, string) {
+	if idx := strings.Index(key[offset:], ":"); idx >= 0 {
+		return key[:offset+idx], key[offset+idx+1:]
+	}
+	return key, ""
+}
+
+// splitFileKey splits a file reference into the path and the selector.
+// The colon of a Windows drive (eg. `C:\config.json`) is not treated as the separator.
+func splitFileKey(key string) (string, string) {
+	offset := 0
+	if len(key) >= 2 && key[1] == ':' && unicode.IsLetter(rune(key[0])) &&
+		(len(key) == 2 || key[2] == '\\' || key[2] == '/') {
+		offset = 2
+	}
+	return splitKey(key, offset)
+}
+
+// splitSecretKey splits a secret reference into the secret name (or ARN) and the selector
+func splitSecretKey(key string) (string, string) {
+	if !strings.HasPrefix(key, "arn:") {
+		return splitKey(key, 0)
+	}
+	// arn:partition:secretsmanager:region:account:secret:name
+	offset := 0
+	for part := 0; part < 6; part++ {
+		idx := strings.Index(key[offset:], ":")
+		if idx < 0 {
+			return key, ""
+		}
+		offset += idx + 1
+	}
+	return splitKey(key, offset)
+}
+
+// selectPath walks a parsed JSON/YAML document along a dotted path
+func selectPath(document interface{}, selector string) (interface{}, error) {
+	current := document
+	for _, part := range strings.Split(selector, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}, map[interface{}]interface{}:
+			value, ok := cast.ToStringMap(node)[part]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", selector)
+			}
+			current = value
+		case []interface{}:
+			idx, err := cast.ToIntE(part)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, fmt.Errorf("%s not found", selector)
+			}
+			current = node[idx]
+		default:
+			return nil, fmt.Errorf("%s not found", selector)
+		}
+	}
+	return current, nil
+}
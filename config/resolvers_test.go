@@ -0,0 +1,100 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitFileKey(t *testing.T) {
+	tests := []struct {
+		key, path, selector string
+	}{
+		{key: "config.json", path: "config.json"},
+		{key: "./config.json:db.host", path: "./config.json", selector: "db.host"},
+		{key: "config.json:urls.http://example.com", path: "config.json", selector: "urls.http://example.com"},
+		{key: `C:\config\app.json`, path: `C:\config\app.json`},
+		{key: `C:\config\app.json:db.host`, path: `C:\config\app.json`, selector: "db.host"},
+		{key: "d:/config/app.yaml:a:b", path: "d:/config/app.yaml", selector: "a:b"},
+		{key: "ab:c", path: "ab", selector: "c"},
+		{key: "a:b", path: "a", selector: "b"},
+	}
+	for _, test := range tests {
+		path, selector := splitFileKey(test.key)
+		if path != test.path || selector != test.selector {
+			t.Errorf("splitFileKey(%q) = %q, %q, want %q, %q", test.key, path, selector, test.path, test.selector)
+		}
+	}
+}
+
+func TestSplitSecretKey(t *testing.T) {
+	arn := "arn:aws:secretsmanager:ap-southeast-1:123456789012:secret:app/dev-AbCdEf"
+	tests := []struct {
+		key, name, selector string
+	}{
+		{key: "app/dev", name: "app/dev"},
+		{key: "app/dev:password", name: "app/dev", selector: "password"},
+		{key: "app/dev:urls.a:b", name: "app/dev", selector: "urls.a:b"},
+		{key: arn, name: arn},
+		{key: arn + ":password", name: arn, selector: "password"},
+		{key: arn + ":a:b", name: arn, selector: "a:b"},
+		{key: "arn:aws:secretsmanager", name: "arn:aws:secretsmanager"},
+	}
+	for _, test := range tests {
+		name, selector := splitSecretKey(test.key)
+		if name != test.name || selector != test.selector {
+			t.Errorf("splitSecretKey(%q) = %q, %q, want %q, %q", test.key, name, selector, test.name, test.selector)
+		}
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bifrost-resolvers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"plain.txt":   "  hello\n",
+		"config.json": `{"db": {"host": "localhost", "ports": [5432, 5433]}, "a:b": "colon"}`,
+		"config.yaml": "db:\n  host: localhost\n  ports: [5432, 5433]\n\"a:b\": colon\n",
+		"config.txt":  "db.host=localhost",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		key     string
+		want    interface{}
+		wantErr bool
+	}{
+		{key: "plain.txt", want: "hello"},
+		{key: "config.json:db.host", want: "localhost"},
+		{key: "config.json:db.ports.1", want: float64(5433)},
+		{key: "config.json:a:b", want: "colon"},
+		{key: "config.json:db", want: map[string]interface{}{"host": "localhost", "ports": []interface{}{float64(5432), float64(5433)}}},
+		{key: "config.yaml:db.host", want: "localhost"},
+		{key: "config.yaml:db.ports.0", want: 5432},
+		{key: "config.yaml:a:b", want: "colon"},
+		{key: "config.yaml:db", want: map[string]interface{}{"host": "localhost", "ports": []interface{}{5432, 5433}}},
+		{key: "config.json:db.user", wantErr: true},
+		{key: "config.json:db.ports.2", wantErr: true},
+		{key: "config.txt:db.host", wantErr: true},
+		{key: "missing.json", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := resolveFile(filepath.Join(dir, test.key))
+		if (err != nil) != test.wantErr {
+			t.Errorf("resolveFile(%q) returned error %v, want error %v", test.key, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("resolveFile(%q) = %#v, want %#v", test.key, got, test.want)
+		}
+	}
+}
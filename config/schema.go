@@ -0,0 +1,283 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kind is the type of a value in the config file
+type Kind string
+
+const (
+	KindAny        Kind = "any"
+	KindString     Kind = "string"
+	KindInt        Kind = "integer"
+	KindBool       Kind = "boolean"
+	KindStringList Kind = "string list"
+	KindStringMap  Kind = "string map"
+	KindObject     Kind = "object"
+	KindMap        Kind = "map"
+	KindList       Kind = "list"
+)
+
+// Field describes a value in the config file
+type Field struct {
+	Kind        Kind
+	Description string
+
+	// Fields holds the known keys of a KindObject
+	Fields map[string]*Field
+	// AllowUnknownFields allows keys not present in Fields for a KindObject
+	AllowUnknownFields bool
+	// Elem is the schema of the values of a KindMap or the items of a KindList
+	Elem *Field
+
+	// Enum restricts a string value (or every item of a string list) to the given values
+	Enum []string
+	// Min and Max restrict the range of an integer value when set
+	Min *int
+	Max *int
+	// Validate is an additional check run on a string value (or every item of a string list)
+	Validate func(value string) error
+	// MergeStrategy decides how a list in a function's config is merged with defaults. Lists are replaced by default.
+	MergeStrategy MergeStrategy
+}
+
+func stringField(description string) *Field {
+	return &Field{Kind: KindString, Description: description}
+}
+
+func intField(description string, min *int, max *int) *Field {
+	return &Field{Kind: KindInt, Description: description, Min: min, Max: max}
+}
+
+// bound returns a pointer to a bound of an intField
+func bound(value int) *int {
+	return &value
+}
+
+func boolField(description string) *Field {
+	return &Field{Kind: KindBool, Description: description}
+}
+
+func stringListField(description string) *Field {
+	return &Field{Kind: KindStringList, Description: description}
+}
+
+func stringMapField(description string) *Field {
+	return &Field{Kind: KindStringMap, Description: description}
+}
+
+func objectField(description string, fields map[string]*Field) *Field {
+	return &Field{Kind: KindObject, Description: description, Fields: fields}
+}
+
+// Runtimes is the list of lambda runtimes accepted in the config
+var Runtimes = []string{
+	"nodejs", "nodejs4.3", "nodejs6.10", "nodejs8.10", "nodejs10.x", "nodejs12.x", "nodejs14.x", "nodejs16.x", "nodejs18.x",
+	"python2.7", "python3.6", "python3.7", "python3.8", "python3.9", "python3.10", "python3.11",
+	"ruby2.5", "ruby2.7", "ruby3.2",
+	"java8", "java8.al2", "java11", "java17",
+	"go1.x",
+	"dotnetcore1.0", "dotnetcore2.0", "dotnetcore2.1", "dotnetcore3.1", "dotnet6",
+	"provided", "provided.al2",
+}
+
+// LayerRuntimes is the list of runtimes layers can be built for. Layers are built with pip into a python directory.
+var LayerRuntimes = []string{"python2.7", "python3.6", "python3.7", "python3.8", "python3.9", "python3.10", "python3.11"}
+
+// CognitoTriggers is the list of cognito user pool triggers a function can be attached to
+var CognitoTriggers = []string{
+	"PreSignUp", "CustomMessage", "PostConfirmation", "PreAuthentication", "PostAuthentication",
+	"DefineAuthChallenge", "CreateAuthChallenge", "VerifyAuthChallengeResponse", "PreTokenGeneration", "UserMigration",
+}
+
+// HttpMethods is the list of methods accepted in `api.resources`
+var HttpMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "ANY"}
+
+var resourcePathRegex = regexp.MustCompile(`^/?[a-zA-Z0-9._\-{}+/]*$`)
+
+// validateApiResource checks that an api resource is of the format `METHOD:path`
+func validateApiResource(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("%q is not of the format METHOD:path", value)
+	}
+	method := strings.ToUpper(parts[0])
+	validMethod := false
+	for _, m := range HttpMethods {
+		validMethod = validMethod || m == method
+	}
+	if !validMethod {
+		return fmt.Errorf("%q has an invalid method %s. expected one of %s", value, parts[0], strings.Join(HttpMethods, ", "))
+	}
+	if !resourcePathRegex.MatchString(parts[1]) {
+		return fmt.Errorf("%q has an invalid path %s", value, parts[1])
+	}
+	return nil
+}
+
+// functionFields returns the fields that can be set on a function (and in defaults)
+func functionFields() map[string]*Field {
+	fields := baseFunctionFields()
+	fields["stages"] = &Field{
+		Kind:        KindMap,
+		Description: "Overrides of the function's config by stage",
+		Elem:        objectField("Overrides for the stage", baseFunctionFields()),
+	}
+	return fields
+}
+
+// baseFunctionFields returns the fields that can be set on a function and overridden per stage
+func baseFunctionFields() map[string]*Field {
+	return map[string]*Field{
+		"stage":        stringField("Stage the function is deployed to. Used as the alias name"),
+		"prefix":       stringField("Prefix of the deployed function name"),
+		"suffix":       stringField("Suffix of the deployed function name"),
+		"source":       stringField("Source directory of the function relative to serverless.rootDir"),
+		"runtime":      {Kind: KindString, Description: "Lambda runtime", Enum: Runtimes},
+		"handler":      stringField("Function handler"),
+		"buildImage":   stringField("Docker image the function is built in (default is serverless.package.buildImages.<runtime>)"),
+		"buildTimeout": intField("Seconds the build may take before it is killed (default is serverless.package.buildTimeout)", bound(1), nil),
+		"interpreter":  stringField("Python interpreter of native builds (default is serverless.package.interpreter)"),
+		"role":         stringField("ARN of the function's execution role"),
+		"roleName":     stringField("Name of the role generated from permissions (default is the function name with a -role suffix)"),
+		"permissions": {
+			Kind:        KindList,
+			Description: "IAM statements of a generated execution role. Used when role is not set",
+			Elem: objectField("IAM statement", map[string]*Field{
+				"Sid":         stringField("Statement ID"),
+				"Effect":      {Kind: KindString, Description: "Effect of the statement (default is Allow)", Enum: []string{"Allow", "Deny"}},
+				"Action":      {Kind: KindAny, Description: "Action or list of actions"},
+				"NotAction":   {Kind: KindAny, Description: "Action or list of actions excluded"},
+				"Resource":    {Kind: KindAny, Description: "Resource ARN or list of resource ARNs"},
+				"NotResource": {Kind: KindAny, Description: "Resource ARN or list of resource ARNs excluded"},
+				"Condition":   {Kind: KindAny, Description: "Conditions of the statement"},
+			}),
+		},
+		"memorySize":             intField("Memory in MB", bound(128), bound(10240)),
+		"timeout":                intField("Timeout in seconds", bound(1), bound(900)),
+		"environment":            stringMapField("Environment variables. Names are upper-cased"),
+		"tags":                   stringMapField("Tags"),
+		"layers":                 stringListField("Layers in serverless.layers (by name) or layer version ARNs"),
+		"reservedConcurrency":    intField("Reserved concurrent executions", bound(0), nil),
+		"provisionedConcurrency": intField("Provisioned concurrent executions of the stage alias", bound(0), nil),
+		"deadLetterTarget":       stringField("ARN of the SQS queue or SNS topic failed asynchronous events are sent to"),
+		"tracing":                {Kind: KindString, Description: "X-Ray tracing mode", Enum: []string{"Active", "PassThrough"}},
+		"ephemeralStorage":       intField("Size of /tmp in MB", bound(512), bound(10240)),
+		"async": objectField("Asynchronous invocation config of the stage alias", map[string]*Field{
+			"maximumRetryAttempts": intField("Maximum number of retries of failed events", bound(0), bound(2)),
+			"maximumEventAge":      intField("Maximum age of events in seconds", bound(60), bound(21600)),
+			"onSuccess":            stringField("ARN of the destination of successful invocations"),
+			"onFailure":            stringField("ARN of the destination of failed invocations"),
+		}),
+		"vpcConfig": objectField("VPC configuration", map[string]*Field{
+			"securityGroupIds": stringListField("Security group IDs"),
+			"subnetIds":        stringListField("Subnet IDs"),
+		}),
+		"api": objectField("API Gateway bindings", map[string]*Field{
+			"resource":       {Kind: KindString, Description: "A single METHOD:path resource", Validate: validateApiResource},
+			"resources":      {Kind: KindStringList, Description: "METHOD:path resources", Validate: validateApiResource},
+			"wsResource":     stringField("A single WebSocket route key"),
+			"wsResources":    stringListField("WebSocket route keys"),
+			"authorizerId":   stringField("REST API authorizer backed by this function"),
+			"wsAuthorizerId": stringField("WebSocket API authorizer backed by this function"),
+		}),
+		"cognito": objectField("Cognito bindings", map[string]*Field{
+			"triggers": {Kind: KindStringList, Description: "User pool triggers", Enum: CognitoTriggers},
+		}),
+	}
+}
+
+// Schema returns the schema of the config file
+func Schema() *Field {
+	return &Field{
+		Kind:               KindObject,
+		Description:        "bifrost configuration",
+		AllowUnknownFields: true,
+		Fields: map[string]*Field{
+			"region":     stringField("AWS region"),
+			"include":    stringListField("Config files (or glob patterns) merged into this config"),
+			"endpoints":  stringMapField("AWS endpoint overrides by service, e.g. logs: http://localhost:4566"),
+			"maxRetries": intField("Maximum retries of throttled or conflicting AWS calls (default is 8)", bound(0), nil),
+			"serverless": objectField("Functions and their packaging", map[string]*Field{
+				"rootDir": stringField("Root directory of the function sources"),
+				"mergeStrategies": {
+					Kind:        KindStringMap,
+					Description: "How lists in defaults are merged into functions by key (append or replace)",
+					Enum:        []string{string(MergeAppend), string(MergeReplace)},
+				},
+				"prefix":                        stringField("Default prefix of deployed function names"),
+				"suffix":                        stringField("Default suffix of deployed function names"),
+				"provisionedConcurrencyTimeout": intField("Seconds to wait for provisioned concurrency to be ready (default is 600)", bound(1), nil),
+				"retainVersions":                intField("Number of most recent function versions kept when pruning after a deploy", bound(1), nil),
+				"package": objectField("Packaging options", map[string]*Field{
+					"BuildDir":           stringField("Build directory relative to rootDir"),
+					"RequirementsFile":   stringField("Requirements file name within each function's source"),
+					"GlobalRequirements": stringListField("Requirements files installed into every function"),
+					"GlobalIncludes":     stringListField("Files and directories copied into every function"),
+					"cleanup":            boolField("Remove intermediate build directories"),
+					"buildTimeout":       intField("Seconds a build may take before it is killed (default is no limit)", bound(1), nil),
+					"buildImages":        stringMapField("Docker images functions are built in by runtime (default is lambci/lambda:build-<runtime>)"),
+					"buildMode": {
+						Kind:        KindString,
+						Description: "Build functions and layers in docker containers or on the host (default is docker)",
+						Enum:        []string{"docker", "native"},
+					},
+					"interpreter": stringField("Python interpreter of native builds (default is python<version> of the runtime if installed, or python3)"),
+				}),
+				"layers": {
+					Kind:        KindMap,
+					Description: "Lambda layers by name",
+					Elem: objectField("Layer", map[string]*Field{
+						"name":             stringField("Published layer name (default is serverless.prefix + name + serverless.suffix)"),
+						"source":           stringField("Source directory relative to rootDir (default is the layer name)"),
+						"requirementsFile": stringField("Requirements file within the source (default is serverless.package.RequirementsFile)"),
+						"runtimes":         {Kind: KindStringList, Description: "Compatible python runtimes. The layer is built for the first one.", Enum: LayerRuntimes},
+						"description":      stringField("Description of the published layer versions"),
+					}),
+				},
+				"functions": {
+					Kind:        KindMap,
+					Description: "Functions by name",
+					Elem:        objectField("Function", functionFields()),
+				},
+			}),
+			"defaults": objectField("Defaults applied to every function", functionFields()),
+			"apiGateway": objectField("API Gateway configuration", map[string]*Field{
+				"restApiId":                  stringField("REST API ID"),
+				"wsApiId":                    stringField("WebSocket API ID"),
+				"resourcePrefix":             stringField("Prefix of every resource path"),
+				"stageVariables":             stringMapField("Stage variables passed to functions by `bifrost serve`"),
+				"wsRouteSelectionExpression": stringField("Route selection expression of the WebSocket API used by `bifrost serve`"),
+				"binaryMediaTypes":           stringListField("Media types `bifrost serve` passes to functions as base64 encoded bodies"),
+			}),
+			"local": objectField("Local invocation by `bifrost invoke local` and `bifrost serve`", map[string]*Field{
+				"awsCredentials": boolField("Pass the host's AWS credentials to functions (default is false)"),
+			}),
+			"docker": objectField("Docker configuration", map[string]*Field{
+				"pullPolicy": {
+					Kind:        KindString,
+					Description: "When images are pulled (default is always)",
+					Enum:        []string{"always", "if-not-present", "never"},
+				},
+				"cache":     boolField("Mount the dependency caches of each runtime into build containers (default is true)"),
+				"cacheDir":  stringField("Directory of the dependency caches (default is ~/.cache/bifrost)"),
+				"buildUser": stringField("User builds run as within the build container, e.g. root (default is the host user on linux)"),
+				"registries": {
+					Kind:        KindMap,
+					Description: "Registry credentials by registry host, e.g. ghcr.io. Credentials of the docker CLI are used otherwise",
+					Elem: objectField("Registry credentials", map[string]*Field{
+						"username":      stringField("User name"),
+						"password":      stringField("Password or access token"),
+						"identityToken": stringField("Identity token used instead of the user name and password"),
+					}),
+				},
+			}),
+			"cognito": objectField("Cognito configuration", map[string]*Field{
+				"userPools": stringMapField("User pool IDs by stage"),
+			}),
+		},
+	}
+}
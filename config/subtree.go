@@ -1,39 +1,40 @@
 package config
 
 import (
+	"github.com/niranjan94/bifrost/utils"
 	"github.com/spf13/cast"
 	"github.com/spf13/viper"
+	"strings"
 )
 
 // GetStringMapSub returns a map of subtrees at a key
-// can also apply defaults if required
+// can also apply defaults if required.
+// Overrides in the `stages` block of a subtree for its stage are merged into it.
 func GetStringMapSub(key string, withDefaultsApplied bool) map[string]*viper.Viper {
 	stringMap := cast.ToStringMap(getResolvedStringMapValue(key))
 	newMap := map[string]*viper.Viper{}
-	defaults := viper.GetStringMap("defaults")
-	for k := range stringMap {
-		sub := viper.Sub(key + "." + k)
+	for k, v := range stringMap {
+		subtree := cast.ToStringMap(v)
 		if withDefaultsApplied {
-			for k, v := range defaults {
-				switch cv := v.(type) {
-				case map[string]string:
-					defaultsMap := cv
-					for k, v := range sub.GetStringMapString(k) {
-						defaultsMap[k] = v
-					}
-					sub.Set(k, v)
-				case map[string]interface{}:
-					defaultsMap := cv
-					for k, v := range sub.GetStringMap(k) {
-						defaultsMap[k] = v
-					}
-					sub.Set(k, v)
-				default:
-					sub.SetDefault(k, v)
-				}
-			}
+			subtree = MergeDefaults(GetStringMap("defaults"), subtree)
 		}
+		sub := viper.New()
+		utils.Must(sub.MergeConfigMap(applyStageOverrides(subtree)))
 		newMap[k] = sub
 	}
 	return newMap
-}
\ No newline at end of file
+}
+
+// applyStageOverrides deep-merges the overrides in the `stages` block of a subtree for its stage into it.
+// The stage of the subtree falls back to the default stage.
+func applyStageOverrides(subtree map[string]interface{}) map[string]interface{} {
+	stage := cast.ToString(subtree["stage"])
+	if stage == "" {
+		stage = GetString("defaults.stage")
+	}
+	overrides := cast.ToStringMap(cast.ToStringMap(subtree["stages"])[strings.ToLower(stage)])
+	if len(overrides) == 0 {
+		return subtree
+	}
+	return mergeMaps(subtree, overrides, "", nil)
+}
@@ -0,0 +1,424 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a problem found at a key in a config file
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Key     string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.File, e.Line, e.Column, e.Key, e.Message)
+}
+
+// ValidationErrors is a list of problems found in the config files
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validator walks a parsed config file and collects the problems found in it.
+// Warnings are problems that do not stop the config from working, such as keys that differ in case.
+type validator struct {
+	file     string
+	errors   ValidationErrors
+	warnings ValidationErrors
+}
+
+func (v *validator) newError(node *yaml.Node, key string, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{
+		File:    v.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Key:     key,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (v *validator) addError(node *yaml.Node, key string, format string, args ...interface{}) {
+	v.errors = append(v.errors, v.newError(node, key, format, args...))
+}
+
+func (v *validator) addWarning(node *yaml.Node, key string, format string, args ...interface{}) {
+	v.warnings = append(v.warnings, v.newError(node, key, format, args...))
+}
+
+// describeNode returns a human readable name of the kind of a node
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a map"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+// offlineReferenceTypes are the reference types resolved during validation.
+// Other types (eg. ssm or secret) may call AWS and are only checked for their syntax.
+var offlineReferenceTypes = map[string]bool{"opt": true, "env": true}
+
+// scalarValue returns the value of a scalar node with offline references resolved.
+// ok is false if the value cannot be checked, as it contains other references or an invalid reference.
+func (v *validator) scalarValue(node *yaml.Node, key string) (value string, ok bool) {
+	if !isReference(node.Value) {
+		return node.Value, true
+	}
+	value, ok, err := resolveOffline(node.Value, false)
+	if err != nil {
+		v.addError(node, key, "%s", err)
+		return "", false
+	}
+	return value, ok
+}
+
+// resolveOffline checks the syntax of every reference within the value and resolves those of offlineReferenceTypes.
+// known is false if the value contains references that were not resolved.
+func resolveOffline(value string, nested bool) (resolved string, known bool, err error) {
+	var builder strings.Builder
+	known = true
+	for idx := 0; idx < len(value); {
+		if strings.HasPrefix(value[idx:], referenceEscape) {
+			builder.WriteString(referenceStart)
+			idx += len(referenceEscape)
+			continue
+		}
+		if !strings.HasPrefix(value[idx:], referenceStart) {
+			builder.WriteByte(value[idx])
+			idx++
+			continue
+		}
+		end := findReferenceEnd(value, idx)
+		if end < 0 {
+			// an unclosed `${` within a quoted default is kept as is
+			if nested {
+				builder.WriteString(value[idx:])
+				break
+			}
+			return "", false, fmt.Errorf("reference %s is not closed. use $${ for a literal ${", value[idx:])
+		}
+		content, contentKnown, err := resolveOffline(value[idx+len(referenceStart):end], true)
+		if err != nil {
+			return "", false, err
+		}
+		idx = end + 1
+		if !contentKnown {
+			known = false
+			continue
+		}
+		if !isTypedReference(content) {
+			builder.WriteString(referenceStart + content + referenceEnd)
+			continue
+		}
+		parsed, err := parseReference(content)
+		if err != nil {
+			return "", false, fmt.Errorf("%s%s%s: %s", referenceStart, content, referenceEnd, err)
+		}
+		if !offlineReferenceTypes[parsed.referenceType] {
+			known = false
+			continue
+		}
+		resolvedValue, err := parsed.resolver.Resolve(parsed.key)
+		if err != nil || isEmptyValue(resolvedValue) {
+			// missing values are reported when the references are checked
+			if !parsed.hasDefault && !parsed.isOptional {
+				known = false
+				continue
+			}
+			resolvedValue = parsed.defaultValue
+		}
+		builder.WriteString(cast.ToString(resolvedValue))
+	}
+	return builder.String(), known, nil
+}
+
+// checkString runs the enum and custom validations of a field on a string value
+func (v *validator) checkString(node *yaml.Node, field *Field, key string, value string) {
+	if len(field.Enum) > 0 {
+		valid := false
+		for _, allowed := range field.Enum {
+			valid = valid || allowed == value
+		}
+		if !valid {
+			v.addError(node, key, "invalid value %q. expected one of %s", value, strings.Join(field.Enum, ", "))
+		}
+	}
+	if field.Validate != nil {
+		if err := field.Validate(value); err != nil {
+			v.addError(node, key, "%s", err)
+		}
+	}
+}
+
+// mappingPairs returns the key and value nodes of a mapping, following `<<` merge keys
+func mappingPairs(node *yaml.Node) (pairs [][2]*yaml.Node) {
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		keyNode, valueNode := node.Content[idx], resolveAlias(node.Content[idx+1])
+		if keyNode.Value == "<<" {
+			merged := []*yaml.Node{valueNode}
+			if valueNode.Kind == yaml.SequenceNode {
+				merged = valueNode.Content
+			}
+			for _, m := range merged {
+				if m = resolveAlias(m); m.Kind == yaml.MappingNode {
+					pairs = append(pairs, mappingPairs(m)...)
+				}
+			}
+			continue
+		}
+		pairs = append(pairs, [2]*yaml.Node{keyNode, valueNode})
+	}
+	return pairs
+}
+
+// resolveAlias returns the node an alias points to
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// validate checks a node against the schema of a field
+func (v *validator) validate(node *yaml.Node, field *Field, key string) {
+	node = resolveAlias(node)
+	if field.Kind == KindAny || (node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+		return
+	}
+
+	switch field.Kind {
+	case KindString, KindInt, KindBool:
+		if node.Kind != yaml.ScalarNode {
+			v.addError(node, key, "expected %s, got %s", field.Kind, describeNode(node))
+			return
+		}
+		value, ok := v.scalarValue(node, key)
+		if !ok {
+			return
+		}
+		switch field.Kind {
+		case KindString:
+			v.checkString(node, field, key, value)
+		case KindInt:
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				v.addError(node, key, "expected %s, got %s", field.Kind, describeNode(node))
+				return
+			}
+			switch {
+			case field.Max == nil && field.Min != nil && number < *field.Min:
+				v.addError(node, key, "%d is out of range. expected a value of at least %d", number, *field.Min)
+			case field.Min == nil && field.Max != nil && number > *field.Max:
+				v.addError(node, key, "%d is out of range. expected a value of at most %d", number, *field.Max)
+			case field.Min != nil && field.Max != nil && (number < *field.Min || number > *field.Max):
+				v.addError(node, key, "%d is out of range. expected a value between %d and %d", number, *field.Min, *field.Max)
+			}
+		case KindBool:
+			if _, err := strconv.ParseBool(value); err != nil {
+				v.addError(node, key, "expected %s, got %s", field.Kind, describeNode(node))
+			}
+		}
+
+	case KindStringList, KindList:
+		if node.Kind != yaml.SequenceNode {
+			v.addError(node, key, "expected %s, got %s", field.Kind, describeNode(node))
+			return
+		}
+		for idx, item := range node.Content {
+			itemKey := fmt.Sprintf("%s[%d]", key, idx)
+			if field.Kind == KindList {
+				v.validate(item, field.Elem, itemKey)
+				continue
+			}
+			v.validate(item, &Field{Kind: KindString, Enum: field.Enum, Validate: field.Validate}, itemKey)
+		}
+
+	case KindStringMap, KindMap, KindObject:
+		if node.Kind != yaml.MappingNode {
+			v.addError(node, key, "expected %s, got %s", field.Kind, describeNode(node))
+			return
+		}
+		for _, pair := range mappingPairs(node) {
+			keyNode, valueNode := pair[0], pair[1]
+			childKey := joinKey(key, keyNode.Value)
+			switch field.Kind {
+			case KindStringMap:
+				v.validate(valueNode, &Field{Kind: KindString, Enum: field.Enum, Validate: field.Validate}, childKey)
+			case KindMap:
+				v.validate(valueNode, field.Elem, childKey)
+			case KindObject:
+				if childField, ok := field.Fields[keyNode.Value]; ok {
+					v.validate(valueNode, childField, childKey)
+				} else if name, childField := fieldByFold(field, keyNode.Value); childField != nil {
+					// keys are case-insensitive once loaded, so a key in a different case still works
+					v.addWarning(keyNode, childKey, "key %s is documented as %s", keyNode.Value, name)
+					v.validate(valueNode, childField, childKey)
+				} else if !field.AllowUnknownFields {
+					v.addError(keyNode, childKey, "unknown key %s%s", keyNode.Value, suggestKey(field, keyNode.Value))
+				}
+			}
+		}
+	}
+}
+
+// fieldByFold returns the name and schema of the field of an object matching the key case-insensitively
+func fieldByFold(field *Field, key string) (string, *Field) {
+	for name, childField := range field.Fields {
+		if strings.EqualFold(name, key) {
+			return name, childField
+		}
+	}
+	return "", nil
+}
+
+// suggestKey returns a hint for a key of an object that is known under a similar name
+func suggestKey(field *Field, key string) string {
+	var candidates []string
+	for name := range field.Fields {
+		lowerName, lowerKey := strings.ToLower(name), strings.ToLower(key)
+		if strings.HasPrefix(lowerName, lowerKey) || strings.HasPrefix(lowerKey, lowerName) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Strings(candidates)
+	return fmt.Sprintf(". did you mean %s?", strings.Join(candidates, " or "))
+}
+
+// ValidateFile validates the given config file against the schema and logs its warnings.
+// Only YAML and JSON files can be validated.
+func ValidateFile(file string) (ValidationErrors, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	errs, warnings, err := validateDocument(file, contents)
+	for _, warning := range warnings {
+		logrus.Warn(warning)
+	}
+	return errs, err
+}
+
+// validateDocument validates the contents of a config file against the schema
+func validateDocument(file string, contents []byte) (errs ValidationErrors, warnings ValidationErrors, err error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", file, err)
+	}
+	v := &validator{file: file}
+	if len(document.Content) > 0 {
+		v.validate(document.Content[0], Schema(), "")
+	}
+	return v.errors, v.warnings, nil
+}
+
+// Validate validates all the config files that are currently loaded
+func Validate() error {
+	var errs ValidationErrors
+	for _, file := range ConfigFiles() {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			logrus.Debugf("skipping validation of %s. only YAML and JSON files can be validated", file)
+			continue
+		}
+		fileErrors, err := ValidateFile(file)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, fileErrors...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// JSONSchema returns the schema of the config file as a JSON Schema document
+func JSONSchema() map[string]interface{} {
+	document := jsonSchemaFor(Schema())
+	document["$schema"] = "http://json-schema.org/draft-07/schema#"
+	document["title"] = "bifrost.yaml"
+	return document
+}
+
+// referenceJSONSchema matches any string containing a reference
+var referenceJSONSchema = map[string]interface{}{
+	"type":    "string",
+	"pattern": `\$\{`,
+}
+
+func jsonSchemaFor(field *Field) map[string]interface{} {
+	schema := map[string]interface{}{}
+	switch field.Kind {
+	case KindString:
+		schema["type"] = "string"
+		if len(field.Enum) > 0 {
+			schema = map[string]interface{}{"anyOf": []interface{}{
+				map[string]interface{}{"type": "string", "enum": field.Enum},
+				referenceJSONSchema,
+			}}
+		}
+	case KindInt:
+		number := map[string]interface{}{"type": "integer"}
+		if field.Min != nil {
+			number["minimum"] = *field.Min
+		}
+		if field.Max != nil {
+			number["maximum"] = *field.Max
+		}
+		schema["anyOf"] = []interface{}{number, referenceJSONSchema}
+	case KindBool:
+		schema["anyOf"] = []interface{}{map[string]interface{}{"type": "boolean"}, referenceJSONSchema}
+	case KindStringList:
+		schema["type"] = "array"
+		schema["items"] = jsonSchemaFor(&Field{Kind: KindString, Enum: field.Enum})
+	case KindList:
+		schema["type"] = "array"
+		schema["items"] = jsonSchemaFor(field.Elem)
+	case KindStringMap:
+		schema["type"] = "object"
+		schema["additionalProperties"] = map[string]interface{}{"type": []string{"string", "number", "boolean"}}
+		if len(field.Enum) > 0 {
+			schema["additionalProperties"] = jsonSchemaFor(&Field{Kind: KindString, Enum: field.Enum})
+		}
+	case KindMap:
+		schema["type"] = "object"
+		schema["additionalProperties"] = jsonSchemaFor(field.Elem)
+	case KindObject:
+		properties := map[string]interface{}{}
+		for name, child := range field.Fields {
+			properties[name] = jsonSchemaFor(child)
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = field.AllowUnknownFields
+	}
+	if field.Description != "" {
+		schema["description"] = field.Description
+	}
+	return schema
+}
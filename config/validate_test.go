@@ -0,0 +1,375 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// messages returns the problems as `key: message` strings
+func messages(errs ValidationErrors) []string {
+	var lines []string
+	for _, err := range errs {
+		lines = append(lines, err.Key+": "+err.Message)
+	}
+	return lines
+}
+
+// withOfflineResolvers replaces the resolvers calling AWS with ones failing the test for the duration of fn
+func withOfflineResolvers(t *testing.T, fn func()) {
+	for _, referenceType := range []string{"ssm", "secret"} {
+		resolver, _ := GetResolver(referenceType)
+		defer RegisterResolver(referenceType, resolver)
+		RegisterResolver(referenceType, ResolverFunc(func(key string) (interface{}, error) {
+			t.Errorf("%s was resolved during validation", key)
+			return nil, errors.New("offline")
+		}))
+	}
+	fn()
+}
+
+func TestValidateDocument(t *testing.T) {
+	os.Setenv("BIFROST_TEST_RUNTIME", "cobol")
+	defer os.Unsetenv("BIFROST_TEST_RUNTIME")
+
+	tests := []struct {
+		name         string
+		document     string
+		wantErrors   []string
+		wantWarnings []string
+	}{
+		{name: "empty document", document: ""},
+		{
+			name: "unknown keys",
+			document: `
+serverless:
+  functions:
+    hello:
+      memory: 512
+      handlr: main.handler
+  layer: {}
+`,
+			wantErrors: []string{
+				"serverless.functions.hello.memory: unknown key memory. did you mean memorySize?",
+				"serverless.functions.hello.handlr: unknown key handlr",
+				"serverless.layer: unknown key layer. did you mean layers?",
+			},
+		},
+		{
+			name:     "unknown top level keys are allowed",
+			document: "custom:\n  anything: [1, 2]\n",
+		},
+		{
+			name: "keys in a different case",
+			document: `
+serverless:
+  package:
+    buildDir: build
+    requirementsFile: requirements.txt
+  functions:
+    hello:
+      memorysize: 512
+      Timeout: 0
+`,
+			wantErrors: []string{
+				"serverless.functions.hello.Timeout: 0 is out of range. expected a value between 1 and 900",
+			},
+			wantWarnings: []string{
+				"serverless.package.buildDir: key buildDir is documented as BuildDir",
+				"serverless.package.requirementsFile: key requirementsFile is documented as RequirementsFile",
+				"serverless.functions.hello.memorysize: key memorysize is documented as memorySize",
+				"serverless.functions.hello.Timeout: key Timeout is documented as timeout",
+			},
+		},
+		{
+			name: "wrong types",
+			document: `
+region: [a, b]
+include: main.yaml
+serverless:
+  functions:
+    hello:
+      timeout: soon
+      environment: [A=b]
+      layers: {common: true}
+      api:
+        resources: GET:/
+docker:
+  cache: sometimes
+`,
+			wantErrors: []string{
+				`region: expected string, got a list`,
+				`include: expected string list, got "main.yaml"`,
+				`serverless.functions.hello.timeout: expected integer, got "soon"`,
+				`serverless.functions.hello.environment: expected string map, got a list`,
+				`serverless.functions.hello.layers: expected string list, got a map`,
+				`serverless.functions.hello.api.resources: expected string list, got "GET:/"`,
+				`docker.cache: expected boolean, got "sometimes"`,
+			},
+		},
+		{
+			name: "int bounds",
+			document: `
+maxRetries: -1
+defaults:
+  memorySize: 64
+  timeout: 901
+  reservedConcurrency: 0
+  provisionedConcurrency: -2
+  async:
+    maximumRetryAttempts: 3
+    maximumEventAge: 60
+`,
+			wantErrors: []string{
+				"maxRetries: -1 is out of range. expected a value of at least 0",
+				"defaults.memorySize: 64 is out of range. expected a value between 128 and 10240",
+				"defaults.timeout: 901 is out of range. expected a value between 1 and 900",
+				"defaults.provisionedConcurrency: -2 is out of range. expected a value of at least 0",
+				"defaults.async.maximumRetryAttempts: 3 is out of range. expected a value between 0 and 2",
+			},
+		},
+		{
+			name: "enums and api resources",
+			document: `
+defaults:
+  runtime: python4
+  tracing: active
+  cognito:
+    triggers: [PreSignUp, PostSignUp]
+  api:
+    resource: FETCH:/hello
+    resources: ["GET:/hello", "GET", "GET:/hello world"]
+`,
+			wantErrors: []string{
+				`defaults.runtime: invalid value "python4". expected one of ` + strings.Join(Runtimes, ", "),
+				`defaults.tracing: invalid value "active". expected one of Active, PassThrough`,
+				`defaults.cognito.triggers[1]: invalid value "PostSignUp". expected one of ` + strings.Join(CognitoTriggers, ", "),
+				`defaults.api.resource: "FETCH:/hello" has an invalid method FETCH. expected one of ` + strings.Join(HttpMethods, ", "),
+				`defaults.api.resources[1]: "GET" is not of the format METHOD:path`,
+				`defaults.api.resources[2]: "GET:/hello world" has an invalid path /hello world`,
+			},
+		},
+		{
+			name:     "layer runtimes",
+			document: "serverless:\n  layers:\n    common:\n      runtimes: [python3.9, nodejs18.x]\n",
+			wantErrors: []string{
+				`serverless.layers.common.runtimes[1]: invalid value "nodejs18.x". expected one of ` + strings.Join(LayerRuntimes, ", "),
+			},
+		},
+		{
+			name: "merge keys and stages",
+			document: `
+base: &base
+  memorySize: 1
+serverless:
+  functions:
+    hello:
+      <<: *base
+      stages:
+        prod:
+          timeout: 0
+          stages: {}
+`,
+			wantErrors: []string{
+				"serverless.functions.hello.memorySize: 1 is out of range. expected a value between 128 and 10240",
+				"serverless.functions.hello.stages.prod.timeout: 0 is out of range. expected a value between 1 and 900",
+				"serverless.functions.hello.stages.prod.stages: unknown key stages. did you mean stage?",
+			},
+		},
+		{
+			name: "references",
+			document: `
+defaults:
+  runtime: ${env:BIFROST_TEST_RUNTIME}
+  memorySize: ${opt:memory, '64'}
+  timeout: ${ssm:/app/timeout}
+  handler: ${secret:app/dev:handler}
+  tracing: ${?env:BIFROST_TEST_UNSET}
+  deadLetterTarget: ${nope:queue}
+  role: ${env:BIFROST_TEST_ROLE
+  roleName: ${env}
+  suffix: $${literal}
+  prefix: ${stageVariables.prefix}
+`,
+			wantErrors: []string{
+				`defaults.runtime: invalid value "cobol". expected one of ` + strings.Join(Runtimes, ", "),
+				"defaults.memorySize: 64 is out of range. expected a value between 128 and 10240",
+				`defaults.tracing: invalid value "". expected one of Active, PassThrough`,
+				"defaults.deadLetterTarget: ${nope:queue}: unknown reference type nope",
+				"defaults.role: reference ${env:BIFROST_TEST_ROLE is not closed. use $${ for a literal ${",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+
+			withOfflineResolvers(t, func() {
+				errs, warnings, err := validateDocument("bifrost.yaml", []byte(test.document))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := messages(errs); !reflect.DeepEqual(got, test.wantErrors) {
+					t.Errorf("errors = %q, want %q", got, test.wantErrors)
+				}
+				if got := messages(warnings); !reflect.DeepEqual(got, test.wantWarnings) {
+					t.Errorf("warnings = %q, want %q", got, test.wantWarnings)
+				}
+			})
+		})
+	}
+}
+
+func TestValidateDocumentPositions(t *testing.T) {
+	document := "serverless:\n  functions:\n    hello:\n      timeout: 0\n"
+	errs, _, err := validateDocument("bifrost.yaml", []byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "bifrost.yaml:4:16: serverless.functions.hello.timeout: 0 is out of range. expected a value between 1 and 900"
+	if len(errs) != 1 || errs[0].Error() != want {
+		t.Errorf("errors = %v, want %s", errs, want)
+	}
+
+	if _, _, err := validateDocument("bifrost.yaml", []byte("a: [")); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
+
+func TestValidateFixture(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	contents, err := ioutil.ReadFile("testdata/valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withOfflineResolvers(t, func() {
+		errs, warnings, err := validateDocument("testdata/valid.yaml", contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) > 0 || len(warnings) > 0 {
+			t.Errorf("got errors %v and warnings %v, want none", errs, warnings)
+		}
+	})
+}
+
+func TestSuggestKey(t *testing.T) {
+	field := objectField("", map[string]*Field{
+		"memorySize": intField("", nil, nil),
+		"timeout":    intField("", nil, nil),
+		"tags":       stringMapField(""),
+		"tagsFile":   stringField(""),
+	})
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "memory", want: ". did you mean memorySize?"},
+		{key: "memorySizeMB", want: ". did you mean memorySize?"},
+		{key: "tag", want: ". did you mean tags or tagsFile?"},
+		{key: "Time", want: ". did you mean timeout?"},
+		{key: "handler", want: ""},
+	}
+	for _, test := range tests {
+		if got := suggestKey(field, test.key); got != test.want {
+			t.Errorf("suggestKey(%q) = %q, want %q", test.key, got, test.want)
+		}
+	}
+}
+
+func TestValidateApiResource(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "GET:/"},
+		{value: "get:/users/{id}"},
+		{value: "ANY:/{proxy+}"},
+		{value: "POST:users/v1.0/a_b-c"},
+		{value: "GET", wantErr: true},
+		{value: "GET:", wantErr: true},
+		{value: ":/users", wantErr: true},
+		{value: "FETCH:/users", wantErr: true},
+		{value: "GET:/users?id=1", wantErr: true},
+	}
+	for _, test := range tests {
+		if err := validateApiResource(test.value); (err != nil) != test.wantErr {
+			t.Errorf("validateApiResource(%q) = %v, want error %v", test.value, err, test.wantErr)
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	document := JSONSchema()
+	if _, err := json.Marshal(document); err != nil {
+		t.Fatal(err)
+	}
+	if document["$schema"] != "http://json-schema.org/draft-07/schema#" || document["additionalProperties"] != true {
+		t.Errorf("unexpected root schema %v", document)
+	}
+
+	// get walks the schema along the given keys
+	get := func(current map[string]interface{}, keys ...string) map[string]interface{} {
+		for _, key := range keys {
+			next, ok := current[key].(map[string]interface{})
+			if !ok {
+				t.Fatalf("%v not found in the schema", keys)
+			}
+			current = next
+		}
+		return current
+	}
+	function := get(document, "properties", "serverless", "properties", "functions", "additionalProperties")
+	if function["additionalProperties"] != false {
+		t.Error("functions allow unknown keys")
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{
+			name: "bounded int",
+			got:  get(document, "properties", "defaults", "properties", "memorySize")["anyOf"].([]interface{})[0],
+			want: map[string]interface{}{"type": "integer", "minimum": 128, "maximum": 10240},
+		},
+		{
+			name: "zero min",
+			got:  get(document, "properties", "defaults", "properties", "reservedConcurrency")["anyOf"].([]interface{})[0],
+			want: map[string]interface{}{"type": "integer", "minimum": 0},
+		},
+		{
+			name: "enum",
+			got:  get(document, "properties", "defaults", "properties", "tracing")["anyOf"],
+			want: []interface{}{
+				map[string]interface{}{"type": "string", "enum": []string{"Active", "PassThrough"}},
+				referenceJSONSchema,
+			},
+		},
+		{
+			name: "string list",
+			got:  get(document, "properties", "include")["items"],
+			want: map[string]interface{}{"type": "string"},
+		},
+		{
+			name: "stage overrides",
+			got:  get(function, "properties", "stages", "additionalProperties", "properties")["stages"],
+			want: nil,
+		},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package local
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/provision/aws/gateway"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/niranjan94/bifrost/utils/debug"
+	"github.com/sirupsen/logrus"
+)
+
+// Route is an API Gateway resource and method bound to a function
+type Route struct {
+	Method   string
+	Resource string
+	Function *WarmFunction
+	segments []string
+}
+
+// RoutesFor returns the routes of every `api.resources` entry of the functions
+func RoutesFor(warmFunctions []*WarmFunction) []*Route {
+	var routes []*Route
+	for _, f := range warmFunctions {
+		cfg := f.Package().Config
+		resources := cfg.GetStringSlice("api.resources")
+		if singleResource := cfg.GetString("api.resource"); singleResource != "" {
+			resources = append(resources, singleResource)
+		}
+		for _, resourceString := range resources {
+			resource := strings.SplitN(resourceString, ":", 2)
+			if len(resource) < 2 {
+				continue
+			}
+			fullResourcePath := gateway.ResourcePath(resource[1])
+			routes = append(routes, &Route{
+				Method:   strings.ToUpper(resource[0]),
+				Resource: fullResourcePath,
+				Function: f,
+				segments: splitPath(fullResourcePath),
+			})
+		}
+	}
+	return routes
+}
+
+func splitPath(p string) []string {
+	trimmed := strings.Trim(p, "/")
+	if trimmed == "" {
+		return []string{}
+	}
+	return strings.Split(trimmed, "/")
+}
+
+// match checks if the route matches the request path and returns the path parameters and a score.
+// Routes with more literal segments and an exact method score higher.
+func (r *Route) match(method string, requestPath string) (pathParameters map[string]string, score int, ok bool) {
+	if r.Method != "ANY" && r.Method != method {
+		return nil, 0, false
+	}
+	segments := splitPath(requestPath)
+	pathParameters = map[string]string{}
+	for idx, segment := range r.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "+}") {
+			if idx >= len(segments) {
+				return nil, 0, false
+			}
+			pathParameters[strings.TrimSuffix(segment[1:], "+}")] = strings.Join(segments[idx:], "/")
+			return pathParameters, score, true
+		}
+		if idx >= len(segments) {
+			return nil, 0, false
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			pathParameters[segment[1:len(segment)-1]] = segments[idx]
+			score += 1
+			continue
+		}
+		if segment != segments[idx] {
+			return nil, 0, false
+		}
+		score += 2
+	}
+	if len(segments) != len(r.segments) {
+		return nil, 0, false
+	}
+	if r.Method == method {
+		score++
+	}
+	return pathParameters, score, true
+}
+
+// Gateway emulates an API Gateway REST API with lambda proxy integrations
+type Gateway struct {
+	Routes           []*Route
+	Stage            string
+	StageVariables   map[string]string
+	BinaryMediaTypes []string
+}
+
+// NewGateway returns a Gateway for the functions configured with the current stage,
+// `apiGateway.stageVariables` and `apiGateway.binaryMediaTypes`
+func NewGateway(warmFunctions []*WarmFunction) *Gateway {
+	stage := config.GetString("defaults.stage")
+	stageVariables := map[string]string{"lambdaAlias": stage}
+	for k, v := range config.GetStringMapString("apiGateway.stageVariables") {
+		stageVariables[k] = v
+	}
+	return &Gateway{
+		Routes:           RoutesFor(warmFunctions),
+		Stage:            stage,
+		StageVariables:   stageVariables,
+		BinaryMediaTypes: config.GetStringSlice("apiGateway.binaryMediaTypes"),
+	}
+}
+
+// route returns the best matching route for a request
+func (g *Gateway) route(method string, requestPath string) (*Route, map[string]string) {
+	var bestRoute *Route
+	var bestPathParameters map[string]string
+	bestScore := -1
+	for _, r := range g.Routes {
+		if pathParameters, score, ok := r.match(method, requestPath); ok && score > bestScore {
+			bestRoute, bestPathParameters, bestScore = r, pathParameters, score
+		}
+	}
+	return bestRoute, bestPathParameters
+}
+
+// isBinary checks if a content type is one of the binary media types
+func (g *Gateway) isBinary(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	if mediaType == "" {
+		return false
+	}
+	for _, binaryType := range g.BinaryMediaTypes {
+		if binaryType == "*/*" || binaryType == mediaType {
+			return true
+		}
+		if strings.HasSuffix(binaryType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(binaryType, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyResponse is the response of a function behind a lambda proxy integration
+type proxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// singleValues returns the last value of every key
+func singleValues(multiValues map[string][]string) map[string]string {
+	if len(multiValues) == 0 {
+		return nil
+	}
+	values := map[string]string{}
+	for k, v := range multiValues {
+		if len(v) > 0 {
+			values[k] = v[len(v)-1]
+		}
+	}
+	return values
+}
+
+// proxyEvent converts a request to an API Gateway proxy event
+func (g *Gateway) proxyEvent(r *http.Request, route *Route, pathParameters map[string]string) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var eventBody interface{}
+	isBase64Encoded := false
+	if len(body) > 0 {
+		if g.isBinary(r.Header.Get("Content-Type")) || !utf8.Valid(body) {
+			eventBody = base64.StdEncoding.EncodeToString(body)
+			isBase64Encoded = true
+		} else {
+			eventBody = string(body)
+		}
+	}
+
+	multiValueHeaders := map[string][]string{}
+	for k, v := range r.Header {
+		multiValueHeaders[k] = v
+	}
+	if r.Host != "" {
+		multiValueHeaders["Host"] = []string{r.Host}
+	}
+	var queryParameters map[string][]string = r.URL.Query()
+	var eventPathParameters interface{}
+	if len(pathParameters) > 0 {
+		eventPathParameters = pathParameters
+	}
+
+	sourceIp, _, _ := net.SplitHostPort(r.RemoteAddr)
+	now := time.Now()
+	requestId := utils.SHA1Hash(fmt.Sprintf("%s%d", r.RemoteAddr, now.UnixNano()))
+
+	return json.Marshal(map[string]interface{}{
+		"resource":                        route.Resource,
+		"path":                            r.URL.Path,
+		"httpMethod":                      r.Method,
+		"headers":                         singleValues(multiValueHeaders),
+		"multiValueHeaders":               multiValueHeaders,
+		"queryStringParameters":           singleValues(queryParameters),
+		"multiValueQueryStringParameters": nilIfEmpty(queryParameters),
+		"pathParameters":                  eventPathParameters,
+		"stageVariables":                  g.StageVariables,
+		"body":                            eventBody,
+		"isBase64Encoded":                 isBase64Encoded,
+		"requestContext": map[string]interface{}{
+			"accountId":        "000000000000",
+			"apiId":            "local",
+			"resourceId":       "local",
+			"resourcePath":     route.Resource,
+			"httpMethod":       r.Method,
+			"path":             "/" + g.Stage + r.URL.Path,
+			"stage":            g.Stage,
+			"requestId":        requestId,
+			"requestTime":      now.UTC().Format("02/Jan/2006:15:04:05 -0700"),
+			"requestTimeEpoch": now.UnixNano() / int64(time.Millisecond),
+			"protocol":         r.Proto,
+			"identity": map[string]interface{}{
+				"sourceIp":  sourceIp,
+				"userAgent": r.UserAgent(),
+			},
+		},
+	})
+}
+
+func nilIfEmpty(values map[string][]string) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// writeJSONError writes an error response in the format API Gateway uses
+func writeJSONError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// ServeHTTP converts the request into a proxy event, invokes the function bound to it and
+// translates the proxy response back
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	route, pathParameters := g.route(r.Method, r.URL.Path)
+	if route == nil {
+		writeJSONError(w, http.StatusForbidden, "Missing Authentication Token")
+		logrus.Warnf("%s %s: no matching resource", r.Method, r.URL.Path)
+		return
+	}
+
+	event, err := g.proxyEvent(r, route, pathParameters)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	payload, functionError, logs, err := route.Function.Invoke(event)
+	debug.PrintMultilineOutput(logs)
+	if err != nil {
+		logrus.Errorf("%s %s: %s", r.Method, r.URL.Path, err)
+		writeJSONError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	var response proxyResponse
+	if functionError || json.Unmarshal(payload, &response) != nil || response.StatusCode == 0 {
+		logrus.Errorf("%s %s: malformed Lambda proxy response from %s: %s", r.Method, r.URL.Path, route.Function.Name(), payload)
+		writeJSONError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	for k, v := range response.Headers {
+		w.Header().Set(k, v)
+	}
+	for k, values := range response.MultiValueHeaders {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		if body, err = base64.StdEncoding.DecodeString(response.Body); err != nil {
+			logrus.Errorf("%s %s: invalid base64 body from %s", r.Method, r.URL.Path, route.Function.Name())
+			writeJSONError(w, http.StatusBadGateway, "Internal server error")
+			return
+		}
+	}
+
+	w.WriteHeader(response.StatusCode)
+	_, _ = w.Write(body)
+	logrus.Infof("%s %s -> %s %d (%s)", r.Method, r.URL.Path, route.Function.Name(), response.StatusCode, time.Since(started).Round(time.Millisecond))
+}
@@ -0,0 +1,220 @@
+// Package local runs functions on the local machine within the docker images of the lambda runtimes
+package local
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/niranjan94/bifrost/utils"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/niranjan94/bifrost/utils/docker"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultMemorySize = 128
+	defaultTimeout    = 3
+	taskDir           = "/var/task"
+)
+
+// Report holds the figures from the REPORT line printed by the runtime at the end of an invocation
+type Report struct {
+	Duration       float64
+	BilledDuration float64
+	MemorySize     int
+	MaxMemoryUsed  int
+}
+
+// InvocationResult holds the outcome of a local invocation
+type InvocationResult struct {
+	// Payload is the value returned by the handler (or the error raised by it)
+	Payload string
+	// Logs are the lines logged by the function, excluding the START, END and REPORT lines
+	Logs []string
+	// Report is nil if the runtime did not print a REPORT line
+	Report *Report
+	// FunctionError is true if the handler raised an error
+	FunctionError bool
+}
+
+var reportFieldRegexes = map[string]*regexp.Regexp{
+	"Duration":        regexp.MustCompile(`\bDuration: ([\d.]+) ms`),
+	"Billed Duration": regexp.MustCompile(`Billed Duration: ([\d.]+) ms`),
+	"Memory Size":     regexp.MustCompile(`Memory Size: (\d+) MB`),
+	"Max Memory Used": regexp.MustCompile(`Max Memory Used: (\d+) MB`),
+}
+
+// parseReport parses the REPORT line of an invocation
+func parseReport(line string) *Report {
+	field := func(name string) string {
+		if matches := reportFieldRegexes[name].FindStringSubmatch(line); matches != nil {
+			return matches[1]
+		}
+		return ""
+	}
+	return &Report{
+		Duration:       cast.ToFloat64(field("Duration")),
+		BilledDuration: cast.ToFloat64(field("Billed Duration")),
+		MemorySize:     cast.ToInt(field("Memory Size")),
+		MaxMemoryUsed:  cast.ToInt(field("Max Memory Used")),
+	}
+}
+
+// RuntimeImage returns the docker image that emulates the lambda environment of a runtime
+func RuntimeImage(runtime string) string {
+	return fmt.Sprintf("docker.io/lambci/lambda:%s", runtime)
+}
+
+// MemorySize returns the configured memory size of a function in MB
+func MemorySize(deploymentPackage *functions.DeploymentPackage) int64 {
+	if memorySize := deploymentPackage.Config.GetInt64("memorySize"); memorySize >= 128 {
+		return memorySize
+	}
+	return defaultMemorySize
+}
+
+// Timeout returns the configured timeout of a function
+func Timeout(deploymentPackage *functions.DeploymentPackage) time.Duration {
+	if timeout := deploymentPackage.Config.GetInt64("timeout"); timeout > 0 {
+		return time.Duration(timeout) * time.Second
+	}
+	return defaultTimeout * time.Second
+}
+
+// AWSCredentials passes the host's AWS credentials to functions. It is set by --aws-credentials.
+var AWSCredentials bool
+
+// credentialsHint logs whether AWS credentials are passed to functions once
+var credentialsHint sync.Once
+
+// passCredentials checks if the host's AWS credentials are passed to functions,
+// as set by --aws-credentials or local.awsCredentials
+func passCredentials() bool {
+	return AWSCredentials || config.GetBool("local.awsCredentials")
+}
+
+// Resources returns the resource limits of a function's container, which has as much memory as the function has on lambda
+func Resources(deploymentPackage *functions.DeploymentPackage) container.Resources {
+	memory := MemorySize(deploymentPackage) * 1024 * 1024
+	return container.Resources{Memory: memory, MemorySwap: memory}
+}
+
+// Environment returns the environment variables a function is run with.
+// It includes the function's environment, the variables set by lambda and, if enabled, the host's AWS credentials.
+func Environment(deploymentPackage *functions.DeploymentPackage) []string {
+	cfg := deploymentPackage.Config
+	region := viper.GetString("region")
+	environment := []string{
+		"AWS_LAMBDA_FUNCTION_NAME=" + deploymentPackage.FunctionName,
+		"AWS_LAMBDA_FUNCTION_VERSION=$LATEST",
+		fmt.Sprintf("AWS_LAMBDA_FUNCTION_MEMORY_SIZE=%d", MemorySize(deploymentPackage)),
+		fmt.Sprintf("AWS_LAMBDA_FUNCTION_TIMEOUT=%d", int(Timeout(deploymentPackage).Seconds())),
+		"AWS_REGION=" + region,
+		"AWS_DEFAULT_REGION=" + region,
+	}
+	if !passCredentials() {
+		credentialsHint.Do(func() {
+			logrus.Info("running functions without AWS credentials. use --aws-credentials to pass the host's credentials")
+		})
+	} else if credentials, err := awsutils.GetSession().Config.Credentials.Get(); err == nil {
+		credentialsHint.Do(func() {
+			logrus.Warnf("passing the host's AWS credentials (%s) to functions", credentials.ProviderName)
+		})
+		environment = append(environment,
+			"AWS_ACCESS_KEY_ID="+credentials.AccessKeyID,
+			"AWS_SECRET_ACCESS_KEY="+credentials.SecretAccessKey,
+		)
+		if credentials.SessionToken != "" {
+			environment = append(environment, "AWS_SESSION_TOKEN="+credentials.SessionToken)
+		}
+	} else {
+		logrus.Warn("not passing AWS credentials to the function: ", err)
+	}
+	for k, v := range cfg.GetStringMapString("environment") {
+		environment = append(environment, strings.ToUpper(k)+"="+v)
+	}
+	return environment
+}
+
+// Invoke runs the built function in the deployment package with the given event in the runtime's docker image
+func Invoke(deploymentPackage *functions.DeploymentPackage, event []byte) (*InvocationResult, error) {
+	cfg := deploymentPackage.Config
+	runtime := cfg.GetString("runtime")
+	if runtime == "" {
+		return nil, fmt.Errorf("%s has no runtime", deploymentPackage.Name)
+	}
+
+	logrus.Infof("preparing %s runtime", runtime)
+
+	c, err := docker.CreateContainer(
+		utils.Context(),
+		&container.Config{
+			Image: RuntimeImage(runtime),
+			Cmd:   []string{cfg.GetString("handler"), string(event)},
+			Env:   Environment(deploymentPackage),
+		},
+		&container.HostConfig{Resources: Resources(deploymentPackage)},
+		functions.PullOptions(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := c.Remove(true); err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	code, err := docker.TarFromZip(deploymentPackage.PackageFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.CopyTo(taskDir, code); err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("invoking %s", deploymentPackage.Name)
+	if err := c.Start(utils.Context()); err != nil {
+		return nil, err
+	}
+
+	// the runtime enforces the function's timeout. this only guards against a hung container.
+	ctx, cancel := context.WithTimeout(utils.Context(), Timeout(deploymentPackage)+30*time.Second)
+	defer cancel()
+	exitCode, err := c.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, err := c.Logs()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &InvocationResult{
+		Payload:       strings.TrimSpace(stdout),
+		FunctionError: exitCode != 0,
+	}
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "REPORT RequestId:"):
+			result.Report = parseReport(line)
+		case strings.HasPrefix(line, "START RequestId:"), strings.HasPrefix(line, "END RequestId:"):
+		default:
+			result.Logs = append(result.Logs, line)
+		}
+	}
+	return result, nil
+}
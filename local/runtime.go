@@ -0,0 +1,187 @@
+package local
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/niranjan94/bifrost/utils/docker"
+	"github.com/sirupsen/logrus"
+)
+
+// runtimeApiPort is the port the lambda API of a runtime container in stay-open mode listens on
+const runtimeApiPort = "9001/tcp"
+
+// WarmFunction is a function running in a runtime container that is kept open between invocations
+type WarmFunction struct {
+	mutex             sync.Mutex
+	deploymentPackage *functions.DeploymentPackage
+	code              []byte
+	container         *docker.Container
+	endpoint          string
+}
+
+// NewWarmFunction returns a WarmFunction for the built function in the deployment package.
+// The runtime container is started on the first invocation.
+func NewWarmFunction(deploymentPackage *functions.DeploymentPackage) (*WarmFunction, error) {
+	f := &WarmFunction{}
+	if err := f.load(deploymentPackage); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// load reads the code of the deployment package into memory,
+// so that the runtime container can be (re)started even if the build directory is cleaned up
+func (f *WarmFunction) load(deploymentPackage *functions.DeploymentPackage) error {
+	code, err := docker.TarFromZip(deploymentPackage.PackageFile)
+	if err != nil {
+		return err
+	}
+	if f.code, err = ioutil.ReadAll(code); err != nil {
+		return err
+	}
+	f.deploymentPackage = deploymentPackage
+	return nil
+}
+
+// Name returns the name of the function
+func (f *WarmFunction) Name() string {
+	return f.deploymentPackage.Name
+}
+
+// Package returns the deployment package of the function
+func (f *WarmFunction) Package() *functions.DeploymentPackage {
+	return f.deploymentPackage
+}
+
+// start starts the runtime container in stay-open mode and waits for its API to be reachable
+func (f *WarmFunction) start() error {
+	cfg := f.deploymentPackage.Config
+	runtime := cfg.GetString("runtime")
+	if runtime == "" {
+		return fmt.Errorf("%s has no runtime", f.deploymentPackage.Name)
+	}
+
+	logrus.Infof("starting %s in a %s runtime", f.deploymentPackage.Name, runtime)
+
+	c, err := docker.CreateContainer(
+		utils.Context(),
+		&container.Config{
+			Image:        RuntimeImage(runtime),
+			Cmd:          []string{cfg.GetString("handler")},
+			Env:          append(Environment(f.deploymentPackage), "DOCKER_LAMBDA_STAY_OPEN=1"),
+			ExposedPorts: nat.PortSet{runtimeApiPort: struct{}{}},
+		},
+		&container.HostConfig{
+			Resources: Resources(f.deploymentPackage),
+			PortBindings: nat.PortMap{
+				runtimeApiPort: []nat.PortBinding{{HostIP: "127.0.0.1"}},
+			},
+			// host.docker.internal only resolves by default with Docker Desktop
+			ExtraHosts: []string{"host.docker.internal:host-gateway"},
+		},
+		functions.PullOptions(),
+	)
+	if err != nil {
+		return err
+	}
+	f.container = c
+
+	if err := c.CopyTo(taskDir, bytes.NewReader(f.code)); err != nil {
+		return err
+	}
+	if err := c.Start(utils.Context()); err != nil {
+		return err
+	}
+
+	port, err := c.HostPort(runtimeApiPort)
+	if err != nil {
+		return err
+	}
+	f.endpoint = fmt.Sprintf("http://127.0.0.1:%s/2015-03-31/functions/%s/invocations", port, f.deploymentPackage.FunctionName)
+
+	for attempt := 0; attempt < 50; attempt++ {
+		var response *http.Response
+		if response, err = http.Get(fmt.Sprintf("http://127.0.0.1:%s/", port)); err == nil {
+			response.Body.Close()
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("runtime of %s did not start: %s", f.deploymentPackage.Name, err)
+}
+
+// stop removes the runtime container if it is running
+func (f *WarmFunction) stop() error {
+	if f.container == nil {
+		return nil
+	}
+	err := f.container.Remove(true)
+	f.container = nil
+	return err
+}
+
+// Invoke invokes the function with the event, starting the runtime container if needed.
+// It returns the payload returned by the function, whether it was a function error and the function's logs.
+func (f *WarmFunction) Invoke(event []byte) (payload []byte, functionError bool, logs string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.container == nil {
+		if err := f.start(); err != nil {
+			_ = f.stop()
+			return nil, false, "", err
+		}
+	}
+
+	request, err := http.NewRequest(http.MethodPost, f.endpoint, bytes.NewReader(event))
+	if err != nil {
+		return nil, false, "", err
+	}
+	request.Header.Set("X-Amz-Log-Type", "Tail")
+
+	client := &http.Client{Timeout: Timeout(f.deploymentPackage) + 30*time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, false, "", err
+	}
+	defer response.Body.Close()
+
+	payload, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, false, "", err
+	}
+	if logResult := response.Header.Get("X-Amz-Log-Result"); logResult != "" {
+		if decoded, err := base64.StdEncoding.DecodeString(logResult); err == nil {
+			logs = string(decoded)
+		}
+	}
+	return payload, response.Header.Get("X-Amz-Function-Error") != "", logs, nil
+}
+
+// Reload replaces the function with a newly built deployment package.
+// The runtime container is restarted on the next invocation.
+func (f *WarmFunction) Reload(deploymentPackage *functions.DeploymentPackage) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.load(deploymentPackage); err != nil {
+		return err
+	}
+	return f.stop()
+}
+
+// Stop removes the runtime container of the function
+func (f *WarmFunction) Stop() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.stop()
+}
@@ -0,0 +1,244 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// rebuildDelay is how long the watcher waits for further changes before rebuilding a function
+const rebuildDelay = 500 * time.Millisecond
+
+// Server serves the functions bound to API Gateway REST and WebSocket APIs on the local machine
+type Server struct {
+	functions  map[string]*WarmFunction
+	gateway    *Gateway
+	wsGateway  *WsGateway
+	httpServer *http.Server
+	watcher    *fsnotify.Watcher
+}
+
+// NewServer returns a Server for the built functions in the deployment packages
+func NewServer(deploymentPackages []*functions.DeploymentPackage) (*Server, error) {
+	s := &Server{
+		functions: map[string]*WarmFunction{},
+	}
+	var warmFunctions []*WarmFunction
+	for _, deploymentPackage := range deploymentPackages {
+		f, err := NewWarmFunction(deploymentPackage)
+		if err != nil {
+			return nil, err
+		}
+		s.functions[deploymentPackage.Name] = f
+		warmFunctions = append(warmFunctions, f)
+	}
+
+	s.gateway = NewGateway(warmFunctions)
+	for _, route := range s.gateway.Routes {
+		logrus.Infof("%s %s -> %s", route.Method, route.Resource, route.Function.Name())
+	}
+	s.wsGateway = NewWsGateway(warmFunctions)
+	for routeKey, f := range s.wsGateway.Routes {
+		logrus.Infof("WS %s -> %s", routeKey, f.Name())
+	}
+	return s, nil
+}
+
+// BoundFunctions returns the names of the functions that have `api.resources` or `api.wsResources`
+func BoundFunctions() []string {
+	var names []string
+	for name, function := range config.GetStringMapSub("serverless.functions", true) {
+		if len(function.GetStringSlice("api.resources")) > 0 || function.GetString("api.resource") != "" ||
+			len(function.GetStringSlice("api.wsResources")) > 0 || function.GetString("api.wsResource") != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ServeHTTP dispatches WebSocket upgrades to the WebSocket gateway, `@connections` requests to its
+// management API and every other request to the REST gateway
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.wsGateway.ServeHTTP(w, r)
+		return
+	}
+	if _, ok := connectionId(r.URL.Path); ok {
+		s.wsGateway.ServeConnections(w, r)
+		return
+	}
+	s.gateway.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the functions on the given address until Shutdown is called.
+// Unless set, the domain name of the WebSocket gateway defaults to host.docker.internal on the same port.
+// On linux, host.docker.internal is the docker bridge of the host, so functions bound to WebSocket routes
+// could not reach the management API on a loopback address and an error is returned instead.
+func (s *Server) ListenAndServe(addr string) error {
+	if s.wsGateway.DomainName == "" {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		if goruntime.GOOS == "linux" && len(s.wsGateway.Routes) > 0 && isLoopback(host) {
+			return fmt.Errorf(
+				"the runtime containers cannot reach the @connections API on %s. listen on --host 0.0.0.0 instead", addr,
+			)
+		}
+		s.wsGateway.DomainName = "host.docker.internal:" + port
+	}
+	s.httpServer = &http.Server{Addr: addr, Handler: s}
+	logrus.Infof("listening on http://%s", addr)
+	if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// isLoopback checks if the host is a loopback address or localhost
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Shutdown stops the server, the watcher and the runtime containers of all functions
+func (s *Server) Shutdown(ctx context.Context) {
+	if s.watcher != nil {
+		_ = s.watcher.Close()
+	}
+	s.wsGateway.Close(ctx)
+	if s.httpServer != nil {
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			logrus.Error(err)
+		}
+	}
+	for _, f := range s.functions {
+		if err := f.Stop(); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
+
+// sourceDir returns the local source directory of a function
+func sourceDir(f *WarmFunction) string {
+	return filepath.Join(utils.GetCwd(), config.GetString("serverless.rootDir"), f.Package().Config.GetString("source"))
+}
+
+// Watch rebuilds functions whose source directories change using the build pipeline and
+// reloads them with the new package
+func (s *Server) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	s.watcher = watcher
+
+	watchedDirs := map[string][]string{}
+	for name, f := range s.functions {
+		if err := s.watchDirs(sourceDir(f), []string{name}, watchedDirs); err != nil {
+			return err
+		}
+	}
+
+	go s.rebuildOnChange(watchedDirs)
+	return nil
+}
+
+// watchDirs adds root and the directories within it to the watcher, except for the build directory and
+// hidden directories, and records that they belong to the functions with the given names
+func (s *Server) watchDirs(root string, names []string, watchedDirs map[string][]string) error {
+	buildDir := filepath.Join(utils.GetCwd(), config.GetString("serverless.rootDir"), config.GetString("serverless.package.BuildDir"))
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p == buildDir || strings.HasPrefix(info.Name(), ".") && p != root {
+			return filepath.SkipDir
+		}
+		if _, watched := watchedDirs[p]; !watched {
+			if err := s.watcher.Add(p); err != nil {
+				return err
+			}
+		}
+		watchedDirs[p] = append(watchedDirs[p], names...)
+		return nil
+	})
+}
+
+// rebuildOnChange waits for changes in the watched directories and rebuilds the affected functions
+// once no further changes happen for rebuildDelay
+func (s *Server) rebuildOnChange(watchedDirs map[string][]string) {
+	pending := map[string]bool{}
+	timer := time.NewTimer(rebuildDelay)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			names := watchedDirs[filepath.Dir(event.Name)]
+			for _, name := range names {
+				pending[name] = true
+			}
+			// directories created after the watcher started are watched as well
+			if event.Op&fsnotify.Create != 0 && len(names) > 0 && !strings.HasPrefix(filepath.Base(event.Name), ".") {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if _, watched := watchedDirs[event.Name]; !watched {
+						if err := s.watchDirs(event.Name, names, watchedDirs); err != nil {
+							logrus.Error(err)
+						}
+					}
+				}
+			}
+			timer.Reset(rebuildDelay)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			logrus.Error(err)
+		case <-timer.C:
+			var names []string
+			for name := range pending {
+				names = append(names, name)
+			}
+			pending = map[string]bool{}
+			s.rebuild(names)
+		}
+	}
+}
+
+// rebuild builds the functions with the given names and reloads them
+func (s *Server) rebuild(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	logrus.Infof("rebuilding %s", strings.Join(names, ", "))
+	for _, deploymentPackage := range functions.BuildFunctions(names) {
+		if f, ok := s.functions[deploymentPackage.Name]; ok {
+			if err := f.Reload(deploymentPackage); err != nil {
+				logrus.Error(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/niranjan94/bifrost/utils/debug"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+)
+
+const (
+	routeKeyConnect    = "$connect"
+	routeKeyDisconnect = "$disconnect"
+	routeKeyDefault    = "$default"
+
+	defaultRouteSelectionExpression = "$request.body.action"
+	connectionsPath                 = "/@connections/"
+)
+
+// connection is a client connected to the WebSocket gateway
+type connection struct {
+	id          string
+	conn        *websocket.Conn
+	writeMutex  sync.Mutex
+	connectedAt time.Time
+	sourceIp    string
+	userAgent   string
+
+	// activityMutex guards lastActiveAt, which is updated by the read loop and read by the management API
+	activityMutex sync.Mutex
+	lastActiveAt  time.Time
+}
+
+// active records that the client sent a message
+func (c *connection) active() {
+	c.activityMutex.Lock()
+	defer c.activityMutex.Unlock()
+	c.lastActiveAt = time.Now()
+}
+
+// lastActive returns when the client last sent a message
+func (c *connection) lastActive() time.Time {
+	c.activityMutex.Lock()
+	defer c.activityMutex.Unlock()
+	return c.lastActiveAt
+}
+
+// send writes a message to the client
+func (c *connection) send(messageType int, message []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.conn.WriteMessage(messageType, message)
+}
+
+// WsGateway emulates an API Gateway WebSocket API with lambda proxy integrations
+// and the `@connections` management API
+type WsGateway struct {
+	// Routes maps route keys to the functions bound to them
+	Routes                   map[string]*WarmFunction
+	RouteSelectionExpression string
+	Stage                    string
+	StageVariables           map[string]string
+	// DomainName is the host the management API is reachable at from within the runtime containers
+	DomainName string
+
+	upgrader    websocket.Upgrader
+	mutex       sync.RWMutex
+	connections map[string]*connection
+	// closed is set by Close, after which new connections are refused
+	closed bool
+	// handlers tracks the connections being served, until their $disconnect invocation returns
+	handlers sync.WaitGroup
+}
+
+// WsRoutesFor returns the functions bound to every `api.wsResources` route key
+func WsRoutesFor(warmFunctions []*WarmFunction) map[string]*WarmFunction {
+	routes := map[string]*WarmFunction{}
+	for _, f := range warmFunctions {
+		cfg := f.Package().Config
+		wsResources := cfg.GetStringSlice("api.wsResources")
+		if singleWsResource := cfg.GetString("api.wsResource"); singleWsResource != "" {
+			wsResources = append(wsResources, singleWsResource)
+		}
+		for _, routeKey := range wsResources {
+			routes[routeKey] = f
+		}
+	}
+	return routes
+}
+
+// NewWsGateway returns a WsGateway for the functions configured with the current stage,
+// `apiGateway.stageVariables` and `apiGateway.wsRouteSelectionExpression`
+func NewWsGateway(warmFunctions []*WarmFunction) *WsGateway {
+	stage := config.GetString("defaults.stage")
+	stageVariables := map[string]string{"lambdaAlias": stage}
+	for k, v := range config.GetStringMapString("apiGateway.stageVariables") {
+		stageVariables[k] = v
+	}
+	routeSelectionExpression := config.GetString("apiGateway.wsRouteSelectionExpression")
+	if routeSelectionExpression == "" {
+		routeSelectionExpression = defaultRouteSelectionExpression
+	}
+	return &WsGateway{
+		Routes:                   WsRoutesFor(warmFunctions),
+		RouteSelectionExpression: routeSelectionExpression,
+		Stage:                    stage,
+		StageVariables:           stageVariables,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		connections: map[string]*connection{},
+	}
+}
+
+// selectRoute evaluates the route selection expression against a message and returns the route key.
+// Only `$request.body.<path>` expressions are supported. `$default` is returned if no route matches.
+func (g *WsGateway) selectRoute(message []byte) string {
+	const bodyPrefix = "$request.body."
+	if strings.HasPrefix(g.RouteSelectionExpression, bodyPrefix) {
+		var body interface{}
+		if json.Unmarshal(message, &body) == nil {
+			value := body
+			for _, part := range strings.Split(strings.TrimPrefix(g.RouteSelectionExpression, bodyPrefix), ".") {
+				value = cast.ToStringMap(value)[part]
+			}
+			if routeKey := cast.ToString(value); routeKey != "" {
+				if _, ok := g.Routes[routeKey]; ok {
+					return routeKey
+				}
+			}
+		}
+	}
+	return routeKeyDefault
+}
+
+// event returns the API Gateway v2 WebSocket event for a route
+func (g *WsGateway) event(c *connection, r *http.Request, routeKey string, eventType string, body []byte) ([]byte, error) {
+	now := time.Now()
+	requestId := utils.SHA1Hash(fmt.Sprintf("%s%d", c.id, now.UnixNano()))
+	requestContext := map[string]interface{}{
+		"routeKey":          routeKey,
+		"eventType":         eventType,
+		"messageDirection":  "IN",
+		"extendedRequestId": requestId,
+		"requestId":         requestId,
+		"requestTime":       now.UTC().Format("02/Jan/2006:15:04:05 -0700"),
+		"requestTimeEpoch":  now.UnixNano() / int64(time.Millisecond),
+		"connectedAt":       c.connectedAt.UnixNano() / int64(time.Millisecond),
+		"stage":             g.Stage,
+		"domainName":        g.DomainName,
+		"connectionId":      c.id,
+		"apiId":             "local",
+		"identity": map[string]interface{}{
+			"sourceIp":  c.sourceIp,
+			"userAgent": c.userAgent,
+		},
+	}
+	event := map[string]interface{}{
+		"requestContext":  requestContext,
+		"stageVariables":  g.StageVariables,
+		"isBase64Encoded": false,
+	}
+	if eventType == "MESSAGE" {
+		requestContext["messageId"] = requestId
+		event["body"] = string(body)
+	}
+	if r != nil {
+		headers := map[string]string{}
+		for k, v := range r.Header {
+			headers[k] = v[len(v)-1]
+		}
+		event["headers"] = headers
+		event["multiValueHeaders"] = r.Header
+		if query := r.URL.Query(); len(query) > 0 {
+			event["queryStringParameters"] = singleValues(query)
+			event["multiValueQueryStringParameters"] = query
+		}
+	}
+	return json.Marshal(event)
+}
+
+// invoke invokes the function bound to a route and returns its proxy response.
+// A nil response is returned without an error if no function is bound to the route.
+func (g *WsGateway) invoke(c *connection, r *http.Request, routeKey string, eventType string, body []byte) (*proxyResponse, error) {
+	f, ok := g.Routes[routeKey]
+	if !ok {
+		return nil, nil
+	}
+	event, err := g.event(c, r, routeKey, eventType, body)
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	payload, functionError, logs, err := f.Invoke(event)
+	debug.PrintMultilineOutput(logs)
+	if err != nil {
+		return nil, err
+	}
+	if functionError {
+		return nil, fmt.Errorf("%s returned an error: %s", f.Name(), payload)
+	}
+	var response proxyResponse
+	_ = json.Unmarshal(payload, &response)
+	logrus.Infof("WS %s %s -> %s %d (%s)", c.id, routeKey, f.Name(), response.StatusCode, time.Since(started).Round(time.Millisecond))
+	return &response, nil
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection if $connect accepts it and
+// routes every message received on it until it is closed
+func (g *WsGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sourceIp, _, _ := net.SplitHostPort(r.RemoteAddr)
+	c := &connection{
+		id:          utils.SHA1Hash(fmt.Sprintf("%s%d", r.RemoteAddr, time.Now().UnixNano()))[0:16],
+		connectedAt: time.Now(),
+		sourceIp:    sourceIp,
+		userAgent:   r.UserAgent(),
+	}
+	c.lastActiveAt = c.connectedAt
+
+	response, err := g.invoke(c, r, routeKeyConnect, "CONNECT", nil)
+	if err != nil {
+		logrus.Error(err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if response != nil && (response.StatusCode < 200 || response.StatusCode > 299) {
+		writeJSONError(w, response.StatusCode, "Forbidden")
+		return
+	}
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	c.conn = conn
+
+	g.mutex.Lock()
+	if g.closed {
+		g.mutex.Unlock()
+		_ = conn.Close()
+		return
+	}
+	g.connections[c.id] = c
+	g.handlers.Add(1)
+	g.mutex.Unlock()
+	logrus.Infof("WS %s connected", c.id)
+
+	defer func() {
+		defer g.handlers.Done()
+		g.mutex.Lock()
+		delete(g.connections, c.id)
+		g.mutex.Unlock()
+		_ = conn.Close()
+		if _, err := g.invoke(c, nil, routeKeyDisconnect, "DISCONNECT", nil); err != nil {
+			logrus.Error(err)
+		}
+		logrus.Infof("WS %s disconnected", c.id)
+	}()
+
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.active()
+		routeKey := g.selectRoute(message)
+		response, err := g.invoke(c, nil, routeKey, "MESSAGE", message)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		if response == nil {
+			logrus.Warnf("WS %s: no function bound to route %s", c.id, routeKey)
+			continue
+		}
+		if response.Body != "" {
+			if err := c.send(messageType, []byte(response.Body)); err != nil {
+				logrus.Error(err)
+			}
+		}
+	}
+}
+
+// connectionId returns the connection id of a `[/stage]/@connections/{id}` path
+func connectionId(requestPath string) (string, bool) {
+	idx := strings.Index(requestPath, connectionsPath)
+	if idx < 0 {
+		return "", false
+	}
+	return strings.Trim(requestPath[idx+len(connectionsPath):], "/"), true
+}
+
+// ServeConnections serves the `@connections` management API that functions use to post to,
+// inspect and disconnect clients
+func (g *WsGateway) ServeConnections(w http.ResponseWriter, r *http.Request) {
+	id, _ := connectionId(r.URL.Path)
+	g.mutex.RLock()
+	c, ok := g.connections[id]
+	g.mutex.RUnlock()
+	if !ok {
+		writeJSONError(w, http.StatusGone, "connection "+id+" is gone")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		message, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := c.send(websocket.TextMessage, message); err != nil {
+			writeJSONError(w, http.StatusGone, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"ConnectedAt":  c.connectedAt.UTC().Format(time.RFC3339),
+			"LastActiveAt": c.lastActive().UTC().Format(time.RFC3339),
+			"Identity": map[string]string{
+				"SourceIp":  c.sourceIp,
+				"UserAgent": c.userAgent,
+			},
+		})
+	case http.MethodDelete:
+		_ = c.conn.Close()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Close disconnects every client and waits until their $disconnect invocations return or ctx is done
+func (g *WsGateway) Close(ctx context.Context) {
+	g.mutex.Lock()
+	g.closed = true
+	for _, c := range g.connections {
+		_ = c.conn.Close()
+	}
+	g.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logrus.Warn("stopped waiting for WebSocket clients to disconnect")
+	}
+}
@@ -0,0 +1,49 @@
+package functions
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// applyEventInvokeConfig applies the retry attempts, maximum event age and destinations of asynchronous invocations
+// given by async to the stage alias of a function, or removes them if async is not set
+func applyEventInvokeConfig(lambdaSvc *lambda.Lambda, cfg *viper.Viper, functionName *string, alias *string) error {
+	if !cfg.IsSet("async") {
+		_, err := lambdaSvc.DeleteFunctionEventInvokeConfig(&lambda.DeleteFunctionEventInvokeConfigInput{
+			FunctionName: functionName,
+			Qualifier:    alias,
+		})
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == lambda.ErrCodeResourceNotFoundException {
+			return nil
+		}
+		if err == nil {
+			logrus.Info("removed asynchronous invocation config")
+		}
+		return err
+	}
+
+	input := &lambda.PutFunctionEventInvokeConfigInput{
+		FunctionName:      functionName,
+		Qualifier:         alias,
+		DestinationConfig: &lambda.DestinationConfig{},
+	}
+	if cfg.IsSet("async.maximumRetryAttempts") {
+		maximumRetryAttempts := cfg.GetInt64("async.maximumRetryAttempts")
+		input.MaximumRetryAttempts = &maximumRetryAttempts
+	}
+	if maximumEventAge := cfg.GetInt64("async.maximumEventAge"); maximumEventAge > 0 {
+		input.MaximumEventAgeInSeconds = &maximumEventAge
+	}
+	if onSuccess := cfg.GetString("async.onSuccess"); onSuccess != "" {
+		input.DestinationConfig.OnSuccess = &lambda.OnSuccess{Destination: &onSuccess}
+	}
+	if onFailure := cfg.GetString("async.onFailure"); onFailure != "" {
+		input.DestinationConfig.OnFailure = &lambda.OnFailure{Destination: &onFailure}
+	}
+
+	logrus.Info("applying asynchronous invocation config")
+	_, err := lambdaSvc.PutFunctionEventInvokeConfig(input)
+	return err
+}
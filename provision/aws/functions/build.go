@@ -2,50 +2,205 @@ package functions
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/niranjan94/bifrost/config"
 	"github.com/niranjan94/bifrost/utils"
 	"github.com/niranjan94/bifrost/utils/debug"
 	"github.com/niranjan94/bifrost/utils/docker"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"os"
 	"path"
 	"path/filepath"
+	goruntime "runtime"
 	"strings"
 	"text/template"
+	"time"
 )
 
 var containerReferences = make(map[string]*docker.Container)
 
+var errBuildTimeout = errors.New("build timed out")
+
 const containerBase = "/cwd"
 
-// getContainerFor returns a reference to a running docker container for the given runtime
+// containerHome is the writable home directory of builds within the container
+const containerHome = "/tmp/bifrost-home"
+
+// BuildImage returns the docker image functions of the runtime are built in.
+// It is the function's buildImage if set, then serverless.package.buildImages.<runtime>,
+// then the lambci build image of the runtime. function may be nil.
+func BuildImage(runtime string, function *viper.Viper) string {
+	if function != nil && function.GetString("buildImage") != "" {
+		return function.GetString("buildImage")
+	}
+	if image, ok := config.GetStringMapString("serverless.package.buildImages")[runtime]; ok && image != "" {
+		return image
+	}
+	return fmt.Sprintf("docker.io/lambci/lambda:build-%s", runtime)
+}
+
+// PullOptions returns the pull policy in docker.pullPolicy and the credentials in docker.registries
+// that images of builds and local runtimes are pulled with
+func PullOptions() docker.PullOptions {
+	registries := map[string]*types.AuthConfig{}
+	for name, value := range config.GetStringMap("docker.registries") {
+		credentials := cast.ToStringMapString(value)
+		registries[name] = &types.AuthConfig{
+			Username:      credentials["username"],
+			Password:      credentials["password"],
+			IdentityToken: credentials["identitytoken"],
+		}
+	}
+	return docker.PullOptions{
+		Policy:     config.GetString("docker.pullPolicy"),
+		Registries: registries,
+	}
+}
+
+// buildUser returns the user builds run as within the container. It is docker.buildUser if set.
+// On linux, it defaults to the host user, so that the build outputs are owned by the host user instead of root.
+func buildUser() string {
+	if user := config.GetString("docker.buildUser"); user != "" {
+		return user
+	}
+	if goruntime.GOOS == "linux" {
+		return fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	}
+	return ""
+}
+
+// buildEnvironment identifies the build container of a runtime and build image
+func buildEnvironment(runtime string, image string) string {
+	return runtime + "@" + image
+}
+
+// getContainerFor returns a reference to a running docker container of the given runtime and build image
 // containers are created only when needed and are re-used if already present
-func getContainerFor(runtime string) (*docker.Container, error) {
-	if container, ok := containerReferences[runtime]; ok {
+func getContainerFor(runtime string, image string) (*docker.Container, error) {
+	if container, ok := containerReferences[buildEnvironment(runtime, image)]; ok {
 		return container, nil
 	}
-	logrus.Infof("preparing %s build environment", runtime)
-	image := fmt.Sprintf("docker.io/lambci/lambda:build-%s", runtime)
+	logrus.Infof("preparing %s build environment", image)
 
+	// the project is read-only within the container, except for the build directory
+	_, buildDir, _ := getDirectories(containerBase, false)
+	_, localBuildDir, _ := getDirectories(utils.GetCwd(), false)
+	if err := os.MkdirAll(localBuildDir, 0751); err != nil {
+		return nil, err
+	}
 	mounts := []mount.Mount{
+		{
+			Type:     mount.TypeBind,
+			Source:   utils.GetCwd(),
+			Target:   containerBase,
+			ReadOnly: true,
+		},
 		{
 			Type:   mount.TypeBind,
-			Source: utils.GetCwd(),
-			Target: containerBase,
+			Source: localBuildDir,
+			Target: buildDir,
 		},
+		{
+			Type:         mount.TypeTmpfs,
+			Target:       containerHome,
+			TmpfsOptions: &mount.TmpfsOptions{Mode: os.FileMode(01777)},
+		},
+	}
+	environment := []string{
+		"HOME=" + containerHome,
+		"XDG_CACHE_HOME=" + path.Join(containerHome, ".cache"),
+	}
+
+	if cacheEnabled() {
+		cache, cacheEnvironment, err := cacheMount(runtime)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, *cache)
+		environment = append(environment, cacheEnvironment...)
 	}
 
-	container, err := docker.StartContainer(image, []string{"sleep"}, []string{"infinity"}, mounts)
+	container, err := docker.StartContainer(
+		utils.Context(),
+		&dockercontainer.Config{
+			Image:      image,
+			Entrypoint: []string{"sleep"},
+			Cmd:        []string{"infinity"},
+			User:       buildUser(),
+			Env:        environment,
+		},
+		&dockercontainer.HostConfig{Mounts: mounts},
+		PullOptions(),
+	)
 	if err != nil {
 		return nil, err
 	}
-	containerReferences[runtime] = container
+	containerReferences[buildEnvironment(runtime, image)] = container
 	return container, nil
 }
 
+// removeContainers removes the build containers started by getContainerFor
+func removeContainers() {
+	logrus.Debug("cleaning up containers")
+	for environment, c := range containerReferences {
+		if err := c.Remove(true); err != nil {
+			logrus.Error(err)
+		}
+		delete(containerReferences, environment)
+	}
+}
+
+// removeContainer removes the container of the build environment, so that the next build gets a new one
+func removeContainer(environment string) {
+	if c, ok := containerReferences[environment]; ok {
+		if err := c.Remove(true); err != nil {
+			logrus.Error(err)
+		}
+		delete(containerReferences, environment)
+	}
+}
+
+// BuildTimeout returns how long the build of a function may take, or 0 if it is not limited.
+// It defaults to serverless.package.buildTimeout.
+func BuildTimeout(function *viper.Viper) time.Duration {
+	function.SetDefault("buildTimeout", config.GetInt("serverless.package.buildTimeout"))
+	return time.Duration(function.GetInt("buildTimeout")) * time.Second
+}
+
+// runBuild runs the build command in the container of the build environment, killing it after the timeout if non-zero.
+// The container of a timed out build is removed, along with the build process.
+func runBuild(environment string, container *docker.Container, command []string, timeout time.Duration) (string, error) {
+	ctx := utils.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	output, err := container.RunCommand(ctx, command)
+	if err != nil && utils.Context().Err() == nil && ctx.Err() == context.DeadlineExceeded {
+		removeContainer(environment)
+		return output, errBuildTimeout
+	}
+	return output, err
+}
+
+// removePartialBuild removes the files and directories of a build that was interrupted
+func removePartialBuild(paths ...string) {
+	for _, p := range paths {
+		if err := os.RemoveAll(p); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
+
 // getDirectories returns a set of directories related to the given cwd working directory
 // they can also be created if not present by passing makeDirectories as true
 func getDirectories(cwd string, makeDirectories bool) (rootDir string, buildDir string, packageDir string) {
@@ -85,7 +240,8 @@ type BuildScriptInput struct {
 	GlobalIncludes     []string
 }
 
-func getFilters() []string {
+// GetFilters returns the names of the functions given by the --only flag
+func GetFilters() []string {
 	filtersString := strings.TrimSpace(viper.GetString("filter"))
 	if filtersString == "" {
 		return []string{}
@@ -119,16 +275,23 @@ cd {{.BuildPath}} && zip -r9 {{.PackageFile}} .
 {{end}}
 `
 
-// Build starts the build process for all of the serverless functions
+// FunctionName returns the name a function is deployed as, i.e. prefix + name + suffix.
+// The prefix and suffix default to serverless.prefix and serverless.suffix.
+func FunctionName(name string, function *viper.Viper) string {
+	function.SetDefault("prefix", config.GetString("serverless.prefix"))
+	function.SetDefault("suffix", config.GetString("serverless.suffix"))
+	return function.GetString("prefix") + name + function.GetString("suffix")
+}
+
+// Build starts the build process for all of the serverless functions matching the --only filter
 func Build() []*DeploymentPackage {
-	defer func() {
-		logrus.Debug("cleaning up containers")
-		for _, c := range containerReferences {
-			if err := c.Remove(true); err != nil {
-				logrus.Error(err)
-			}
-		}
-	}()
+	return BuildFunctions(GetFilters())
+}
+
+// BuildFunctions starts the build process for the serverless functions with the given names.
+// All functions are built if no names are given.
+func BuildFunctions(names []string) []*DeploymentPackage {
+	defer removeContainers()
 
 	functionsMap := config.GetStringMapSub("serverless.functions", true)
 
@@ -149,23 +312,30 @@ func Build() []*DeploymentPackage {
 
 	buildScriptTemplate := template.Must(template.New("buildScriptTemplate").Parse(buildScriptTemplate))
 
-	namePrefix := config.GetString("serverless.prefix")
-	nameSuffix := config.GetString("serverless.suffix")
-
 	var deploymentPackages []*DeploymentPackage
 
-	filters := getFilters()
+	native := false
+	switch mode := BuildMode(); mode {
+	case BuildModeNative:
+		native = true
+	case BuildModeDocker:
+	default:
+		logrus.Errorf("invalid build mode %s. expected %s or %s", mode, BuildModeDocker, BuildModeNative)
+		return nil
+	}
 
 	for name, function := range functionsMap {
 
-		if len(filters) > 0 && !utils.StringSliceContains(filters, name) {
+		if len(names) > 0 && !utils.StringSliceContains(names, name) {
 			continue
 		}
 
-		function.SetDefault("prefix", namePrefix)
-		function.SetDefault("suffix", nameSuffix)
+		if utils.Context().Err() != nil {
+			logrus.Warn("interrupted. not building the remaining functions")
+			break
+		}
 
-		functionName := function.GetString("prefix") + name + function.GetString("suffix")
+		functionName := FunctionName(name, function)
 
 		function.SetDefault("source", name)
 
@@ -182,29 +352,66 @@ func Build() []*DeploymentPackage {
 			continue
 		}
 
-		container, err := getContainerFor(function.GetString("runtime"))
-		if err != nil {
-			logrus.Error(err)
-			continue
-		}
-
+		runtime := function.GetString("runtime")
+		timeout := BuildTimeout(function)
 		buildScriptFilePath := filepath.Join(localBuildDir, "build.sh")
-		os.Remove(buildScriptFilePath)
-		buildScriptFile, err := os.OpenFile(buildScriptFilePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0711)
-		if err != nil {
-			logrus.Error(err)
-			continue
-		}
+		var output string
+		var err error
+
+		if native {
+			interpreter := Interpreter(runtime, function)
+			warnNativePlatform(runtime, interpreter)
+			logrus.Info("building function ", name)
+			output, err = buildNative(input, "", interpreter, timeout)
+		} else {
+			image := BuildImage(runtime, function)
+			var container *docker.Container
+			container, err = getContainerFor(runtime, image)
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
 
-		if _, err = buildScriptFile.Write(buildScript.Bytes()); err != nil {
-			logrus.Error(err)
-			continue
-		}
+			os.Remove(buildScriptFilePath)
+			var buildScriptFile *os.File
+			buildScriptFile, err = os.OpenFile(buildScriptFilePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0711)
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+
+			if _, err = buildScriptFile.Write(buildScript.Bytes()); err != nil {
+				logrus.Error(err)
+				continue
+			}
 
-		buildScriptFile.Close()
+			buildScriptFile.Close()
 
-		logrus.Info("building function ", name)
-		if output, err := container.RunCommand([]string{"/bin/sh", path.Join(input.BuildDir, "build.sh")}); err != nil {
+			logrus.Info("building function ", name)
+			output, err = runBuild(buildEnvironment(runtime, image), container, []string{"/bin/sh", path.Join(input.BuildDir, "build.sh")}, timeout)
+		}
+
+		if err != nil {
+			if err == errBuildTimeout {
+				logrus.Errorf("build of %s timed out after %s. output until then:", name, timeout)
+				debug.PrintMultilineOutput(output)
+				removePartialBuild(
+					filepath.Join(localBuildDir, path.Base(input.SourcePath)),
+					filepath.Join(localPackageDir, packageName),
+				)
+				continue
+			}
+			if utils.Context().Err() != nil {
+				logrus.Warnf("interrupted while building %s. removing its partial build", name)
+				// the build script keeps writing until its container is removed
+				removeContainers()
+				removePartialBuild(
+					filepath.Join(localBuildDir, path.Base(input.SourcePath)),
+					filepath.Join(localPackageDir, packageName),
+					buildScriptFilePath,
+				)
+				break
+			}
 			logrus.Error(err)
 			debug.PrintMultilineOutput(output)
 		}
@@ -0,0 +1,128 @@
+package functions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/mitchellh/go-homedir"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// containerCache is where the dependency cache of a runtime is mounted within the build container
+const containerCache = "/tmp/bifrost-cache"
+
+// cacheDirectories are the directories of the package managers in the dependency cache
+// and the environment variables that point them there
+var cacheDirectories = []struct {
+	dir      string
+	variable string
+	prefix   string
+}{
+	{dir: "pip", variable: "PIP_CACHE_DIR"},
+	{dir: "npm", variable: "npm_config_cache"},
+	{dir: "yarn", variable: "YARN_CACHE_FOLDER"},
+	{dir: "go-mod", variable: "GOMODCACHE"},
+	{dir: "go-build", variable: "GOCACHE"},
+	{dir: "maven", variable: "MAVEN_OPTS", prefix: "-Dmaven.repo.local="},
+}
+
+// CacheDir returns the directory holding the dependency caches of builds.
+// It is docker.cacheDir if set, and ~/.cache/bifrost otherwise.
+// docker expects absolute mount sources, so ~ is expanded and relative directories are resolved from the project.
+func CacheDir() (string, error) {
+	if dir := config.GetString("docker.cacheDir"); dir != "" {
+		dir, err := homedir.Expand(dir)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(utils.GetCwd(), dir)
+		}
+		return dir, nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cache", "bifrost"), nil
+}
+
+// cacheEnabled checks if dependency caches are mounted into build containers, which is set with docker.cache
+func cacheEnabled() bool {
+	return !config.IsSet("docker.cache") || config.GetBool("docker.cache")
+}
+
+// cacheMount returns the mount of the dependency cache of a runtime and the environment variables that point
+// pip, npm, yarn, go and maven at it. Each runtime has its own cache, as built packages are not portable across them.
+func cacheMount(runtime string) (*mount.Mount, []string, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	runtimeCacheDir := filepath.Join(cacheDir, runtime)
+
+	var environment []string
+	for _, c := range cacheDirectories {
+		environment = append(environment, c.variable+"="+c.prefix+path.Join(containerCache, c.dir))
+		// created up front, so that they are owned by the host user
+		if err := os.MkdirAll(filepath.Join(runtimeCacheDir, c.dir), 0755); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &mount.Mount{
+		Type:   mount.TypeBind,
+		Source: runtimeCacheDir,
+		Target: containerCache,
+	}, environment, nil
+}
+
+// CleanCache removes the dependency caches of the given runtimes, or of all runtimes if none are given
+func CleanCache(runtimes []string) error {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	if len(runtimes) == 0 {
+		entries, err := ioutil.ReadDir(cacheDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				logrus.Info("the cache is empty")
+				return nil
+			}
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				runtimes = append(runtimes, entry.Name())
+			}
+		}
+	}
+
+	for _, runtime := range runtimes {
+		if runtime == "" || runtime == "." || runtime == ".." || filepath.Base(runtime) != runtime {
+			return fmt.Errorf("invalid runtime %q", runtime)
+		}
+		runtimeCacheDir := filepath.Join(cacheDir, runtime)
+		if _, err := os.Stat(runtimeCacheDir); os.IsNotExist(err) {
+			logrus.Infof("no cache for %s", runtime)
+			continue
+		}
+		if viper.GetBool("dryRun") {
+			logrus.Infof("would remove the cache of %s at %s. dry run mode. skipping.", runtime, runtimeCacheDir)
+			continue
+		}
+		if err := os.RemoveAll(runtimeCacheDir); err != nil {
+			return err
+		}
+		logrus.Infof("removed the cache of %s at %s", runtime, runtimeCacheDir)
+	}
+	return nil
+}
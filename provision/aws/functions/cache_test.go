@@ -0,0 +1,37 @@
+package functions
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/spf13/viper"
+)
+
+func TestCacheDir(t *testing.T) {
+	defer viper.Reset()
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cacheDir string
+		want     string
+	}{
+		{cacheDir: "", want: filepath.Join(home, ".cache", "bifrost")},
+		{cacheDir: "~/caches/bifrost", want: filepath.Join(home, "caches", "bifrost")},
+		{cacheDir: ".cache", want: filepath.Join(utils.GetCwd(), ".cache")},
+		{cacheDir: "/var/cache/bifrost", want: "/var/cache/bifrost"},
+	}
+	for _, test := range tests {
+		viper.Set("docker.cacheDir", test.cacheDir)
+		got, err := CacheDir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("CacheDir() with docker.cacheDir %q = %q, want %q", test.cacheDir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package functions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/utils"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// applyReservedConcurrency reserves the concurrency given by reservedConcurrency for a function,
+// or removes the reservation if it is not set
+func applyReservedConcurrency(lambdaSvc *lambda.Lambda, cfg *viper.Viper, functionName *string) error {
+	if cfg.IsSet("reservedConcurrency") {
+		reservedConcurrency := cfg.GetInt64("reservedConcurrency")
+		logrus.Infof("reserving a concurrency of %d", reservedConcurrency)
+		_, err := lambdaSvc.PutFunctionConcurrency(&lambda.PutFunctionConcurrencyInput{
+			FunctionName:                 functionName,
+			ReservedConcurrentExecutions: &reservedConcurrency,
+		})
+		return err
+	}
+
+	concurrency, err := lambdaSvc.GetFunctionConcurrency(&lambda.GetFunctionConcurrencyInput{
+		FunctionName: functionName,
+	})
+	if err != nil || concurrency.ReservedConcurrentExecutions == nil {
+		return err
+	}
+	logrus.Info("removing reserved concurrency")
+	_, err = lambdaSvc.DeleteFunctionConcurrency(&lambda.DeleteFunctionConcurrencyInput{
+		FunctionName: functionName,
+	})
+	return err
+}
+
+// provisionedConcurrencyPollInterval is how often the status of provisioned concurrency is checked while waiting for it
+const provisionedConcurrencyPollInterval = 5 * time.Second
+
+// defaultProvisionedConcurrencyTimeout is how long to wait for provisioned concurrency unless
+// serverless.provisionedConcurrencyTimeout is set
+const defaultProvisionedConcurrencyTimeout = 10 * time.Minute
+
+// applyProvisionedConcurrency provisions the concurrency given by provisionedConcurrency on the stage alias of a function,
+// or removes the provisioned concurrency of the alias if it is not set
+func applyProvisionedConcurrency(lambdaSvc *lambda.Lambda, cfg *viper.Viper, functionName *string, alias *string) error {
+	if provisionedConcurrency := cfg.GetInt64("provisionedConcurrency"); provisionedConcurrency > 0 {
+		logrus.Infof("provisioning a concurrency of %d on %s", provisionedConcurrency, *alias)
+		_, err := lambdaSvc.PutProvisionedConcurrencyConfig(&lambda.PutProvisionedConcurrencyConfigInput{
+			FunctionName:                    functionName,
+			Qualifier:                       alias,
+			ProvisionedConcurrentExecutions: &provisionedConcurrency,
+		})
+		return err
+	}
+
+	_, err := lambdaSvc.GetProvisionedConcurrencyConfig(&lambda.GetProvisionedConcurrencyConfigInput{
+		FunctionName: functionName,
+		Qualifier:    alias,
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == lambda.ErrCodeProvisionedConcurrencyConfigNotFoundException {
+			return nil
+		}
+		return err
+	}
+	logrus.Infof("removing provisioned concurrency from %s", *alias)
+	_, err = lambdaSvc.DeleteProvisionedConcurrencyConfig(&lambda.DeleteProvisionedConcurrencyConfigInput{
+		FunctionName: functionName,
+		Qualifier:    alias,
+	})
+	return err
+}
+
+// WaitForProvisionedConcurrency waits until the provisioned concurrency of the stage aliases of the deployed functions is ready.
+// It gives up after serverless.provisionedConcurrencyTimeout seconds.
+func WaitForProvisionedConcurrency(deploymentPackages []*DeploymentPackage) error {
+	if viper.GetBool("dryRun") {
+		return nil
+	}
+
+	timeout := time.Duration(config.GetInt("serverless.provisionedConcurrencyTimeout")) * time.Second
+	if timeout <= 0 {
+		timeout = defaultProvisionedConcurrencyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+
+	for _, deploymentPackage := range deploymentPackages {
+		if deploymentPackage.AliasArn == "" || deploymentPackage.Config.GetInt64("provisionedConcurrency") <= 0 {
+			continue
+		}
+		stage := deploymentPackage.Config.GetString("stage")
+		for {
+			output, err := lambdaSvc.GetProvisionedConcurrencyConfig(&lambda.GetProvisionedConcurrencyConfigInput{
+				FunctionName: &deploymentPackage.FunctionName,
+				Qualifier:    &stage,
+			})
+			if err != nil {
+				return err
+			}
+			status := aws.StringValue(output.Status)
+			if status == lambda.ProvisionedConcurrencyStatusEnumReady {
+				logrus.Infof("provisioned concurrency of %s:%s is ready", deploymentPackage.FunctionName, stage)
+				break
+			}
+			if status == lambda.ProvisionedConcurrencyStatusEnumFailed {
+				return fmt.Errorf(
+					"provisioned concurrency of %s:%s failed: %s",
+					deploymentPackage.FunctionName, stage, aws.StringValue(output.StatusReason),
+				)
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timed out waiting for provisioned concurrency of %s:%s", deploymentPackage.FunctionName, stage)
+			}
+			logrus.Infof(
+				"waiting for provisioned concurrency of %s:%s (%d/%d allocated)",
+				deploymentPackage.FunctionName, stage,
+				aws.Int64Value(output.AllocatedProvisionedConcurrentExecutions),
+				aws.Int64Value(output.RequestedProvisionedConcurrentExecutions),
+			)
+			if err := utils.Sleep(utils.Context(), provisionedConcurrencyPollInterval); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -3,8 +3,9 @@ package functions
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/utils"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
 	"github.com/niranjan94/bifrost/utils/merge"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -24,13 +25,19 @@ type DeploymentPackage struct {
 
 func Deploy(deploymentPackages []*DeploymentPackage) []*DeploymentPackage {
 
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region: aws.String(viper.GetString("region")),
-	}))
-	lambdaSvc := lambda.New(sess)
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+
+	// functions whose deploy was interrupted after lambda was first changed, and those not deployed at all
+	var partiallyDeployed, notDeployed []string
 
 	for idx := range deploymentPackages {
 
+		if utils.Context().Err() != nil {
+			notDeployed = append(notDeployed, deploymentPackages[idx].Name)
+			continue
+		}
+
+		changed := false
 		if err := func() error {
 			deploymentPackage := deploymentPackages[idx]
 
@@ -169,6 +176,12 @@ func Deploy(deploymentPackages []*DeploymentPackage) []*DeploymentPackage {
 
 			if functionRole != "" {
 				functionInput.Role = &functionRole
+			} else if HasGeneratedRole(cfg) {
+				roleArn, err := EnsureExecutionRole(deploymentPackage.Name, cfg)
+				if err != nil {
+					return err
+				}
+				functionInput.Role = &roleArn
 			}
 
 			if functionMemorySize >= 128 {
@@ -187,6 +200,29 @@ func Deploy(deploymentPackages []*DeploymentPackage) []*DeploymentPackage {
 				functionInput.Handler = &functionHandler
 			}
 
+			if tracing := cfg.GetString("tracing"); tracing != "" {
+				functionInput.TracingConfig.Mode = &tracing
+			}
+
+			if deadLetterTarget := cfg.GetString("deadLetterTarget"); deadLetterTarget != "" {
+				functionInput.DeadLetterConfig = &lambda.DeadLetterConfig{TargetArn: &deadLetterTarget}
+			} else if functionInput.DeadLetterConfig != nil {
+				// an empty target removes the dead-letter queue
+				functionInput.DeadLetterConfig = &lambda.DeadLetterConfig{TargetArn: aws.String("")}
+			}
+
+			if ephemeralStorage := cfg.GetInt64("ephemeralStorage"); ephemeralStorage >= 512 {
+				functionInput.EphemeralStorage = &lambda.EphemeralStorage{Size: &ephemeralStorage}
+			}
+
+			if layers := cfg.GetStringSlice("layers"); len(layers) > 0 {
+				layerVersionArns, err := LayerVersionArns(layers)
+				if err != nil {
+					return err
+				}
+				functionInput.Layers = mergeLayers(functionInput.Layers, layerVersionArns)
+			}
+
 			functionArchiveContents, err := ioutil.ReadFile(deploymentPackage.PackageFile)
 			if err != nil {
 				return err
@@ -208,22 +244,41 @@ func Deploy(deploymentPackages []*DeploymentPackage) []*DeploymentPackage {
 				return nil
 			}
 
+			changed = true
 			if shouldCreate {
-				deployed, err = lambdaSvc.CreateFunction(functionInput)
+				deployed, err = createFunction(lambdaSvc, functionInput)
 				if err != nil {
 					return err
 				}
 				deployedCodeSha256 = deployed.CodeSha256
+				if deployed, err = waitForFunctionUpdate(lambdaSvc, deployed.FunctionName); err != nil {
+					return err
+				}
 			} else {
+				revisionId := existingFunction.RevisionId
+				if aws.StringValue(existingFunction.State) == lambda.StatePending ||
+					aws.StringValue(existingFunction.LastUpdateStatus) == lambda.LastUpdateStatusInProgress {
+					logrus.Infof("waiting for the previous update of %s", deploymentPackage.FunctionName)
+					previous, err := waitForFunctionUpdate(lambdaSvc, functionInput.FunctionName)
+					if err != nil {
+						// a failed previous update does not prevent this one
+						logrus.Warn(err)
+					} else {
+						revisionId = previous.RevisionId
+					}
+				}
 				deployed, err = lambdaSvc.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
 					ZipFile:      functionInput.Code.ZipFile,
 					FunctionName: functionInput.FunctionName,
-					RevisionId:   functionOutput.Configuration.RevisionId,
+					RevisionId:   revisionId,
 				})
 				if err != nil {
 					return err
 				}
 				deployedCodeSha256 = deployed.CodeSha256
+				if deployed, err = waitForFunctionUpdate(lambdaSvc, deployed.FunctionName); err != nil {
+					return err
+				}
 				configUpdate := &lambda.UpdateFunctionConfigurationInput{}
 				if err = merge.Merge(functionInput, configUpdate); err != nil {
 					return err
@@ -233,6 +288,9 @@ func Deploy(deploymentPackages []*DeploymentPackage) []*DeploymentPackage {
 				if err != nil {
 					return err
 				}
+				if deployed, err = waitForFunctionUpdate(lambdaSvc, deployed.FunctionName); err != nil {
+					return err
+				}
 				_, err = lambdaSvc.TagResource(&lambda.TagResourceInput{
 					Resource: deployed.FunctionArn,
 					Tags:     functionInput.Tags,
@@ -288,15 +346,42 @@ func Deploy(deploymentPackages []*DeploymentPackage) []*DeploymentPackage {
 			deploymentPackage.AliasArn = *alias.AliasArn
 			deploymentPackage.RevisionId = *deployed.RevisionId
 
+			if err := applyReservedConcurrency(lambdaSvc, cfg, deployed.FunctionName); err != nil {
+				return err
+			}
+
+			if err := applyProvisionedConcurrency(lambdaSvc, cfg, deployed.FunctionName, &stage); err != nil {
+				return err
+			}
+
+			if err := applyEventInvokeConfig(lambdaSvc, cfg, deployed.FunctionName, &stage); err != nil {
+				return err
+			}
+
 			logrus.Infof("published alias %s", *alias.Name)
 			logrus.Infof("deployed %s as %s", deploymentPackage.Name, *deployed.FunctionName)
 
 			return nil
 		}(); err != nil {
 			logrus.Error(err)
+			if utils.Context().Err() != nil && changed {
+				partiallyDeployed = append(partiallyDeployed, deploymentPackages[idx].Name)
+			} else if utils.Context().Err() != nil {
+				notDeployed = append(notDeployed, deploymentPackages[idx].Name)
+			}
 			continue
 		}
 	}
 
+	if len(partiallyDeployed) > 0 {
+		logrus.Errorf(
+			"interrupted while deploying %s. they may be left partially deployed. deploy them again to finish",
+			strings.Join(partiallyDeployed, ", "),
+		)
+	}
+	if len(notDeployed) > 0 {
+		logrus.Warnf("interrupted. not deployed: %s", strings.Join(notDeployed, ", "))
+	}
+
 	return deploymentPackages
 }
@@ -0,0 +1,86 @@
+package functions
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/utils/merge"
+)
+
+// boundFields are the fields Deploy copies from the live configuration with a translator
+var boundFields = map[string]bool{
+	"Environment":   true,
+	"VpcConfig":     true,
+	"TracingConfig": true,
+	"Layers":        true,
+}
+
+// TestMergedFieldTypes checks that merge.Merge can copy every field Deploy copies without a translator,
+// as fields of the same name but different types panic
+func TestMergedFieldTypes(t *testing.T) {
+	pairs := []struct {
+		from, to interface{}
+		bound    map[string]bool
+	}{
+		{&lambda.FunctionConfiguration{}, &lambda.CreateFunctionInput{}, boundFields},
+		{&lambda.CreateFunctionInput{}, &lambda.UpdateFunctionConfigurationInput{}, nil},
+		{&lambda.EnvironmentResponse{}, &lambda.Environment{}, nil},
+		{&lambda.VpcConfigResponse{}, &lambda.VpcConfig{}, nil},
+		{&lambda.TracingConfigResponse{}, &lambda.TracingConfig{}, nil},
+	}
+	for _, pair := range pairs {
+		fromType, toType := reflect.TypeOf(pair.from).Elem(), reflect.TypeOf(pair.to).Elem()
+		for idx := 0; idx < fromType.NumField(); idx++ {
+			field := fromType.Field(idx)
+			toField, ok := toType.FieldByName(field.Name)
+			if !ok || field.PkgPath != "" || pair.bound[field.Name] {
+				continue
+			}
+			if field.Type != toField.Type {
+				t.Errorf("%s.%s is %s but %s.%s is %s", fromType.Name(), field.Name, field.Type, toType.Name(), field.Name, toField.Type)
+			}
+		}
+	}
+}
+
+// TestMergeLiveConfiguration checks that the live settings Deploy does not manage are sent back unchanged on update
+func TestMergeLiveConfiguration(t *testing.T) {
+	live := &lambda.FunctionConfiguration{
+		FunctionName:      aws.String("hello"),
+		Handler:           aws.String("main.handler"),
+		MemorySize:        aws.Int64(256),
+		KMSKeyArn:         aws.String("arn:aws:kms:ap-southeast-1:123456789012:key/1"),
+		EphemeralStorage:  &lambda.EphemeralStorage{Size: aws.Int64(1024)},
+		FileSystemConfigs: []*lambda.FileSystemConfig{{Arn: aws.String("arn:fs"), LocalMountPath: aws.String("/mnt/fs")}},
+		Architectures:     []*string{aws.String("arm64")},
+		PackageType:       aws.String("Zip"),
+	}
+	create := &lambda.CreateFunctionInput{}
+	bindings := []merge.Bind{
+		{From: "Environment", To: "Environment", Translator: func(interface{}) (interface{}, error) { return create.Environment, nil }},
+		{From: "VpcConfig", To: "VpcConfig", Translator: func(interface{}) (interface{}, error) { return create.VpcConfig, nil }},
+		{From: "TracingConfig", To: "TracingConfig", Translator: func(interface{}) (interface{}, error) { return create.TracingConfig, nil }},
+		{From: "Layers", To: "Layers", Translator: func(interface{}) (interface{}, error) { return create.Layers, nil }},
+	}
+	if err := merge.Merge(live, create, bindings...); err != nil {
+		t.Fatal(err)
+	}
+	update := &lambda.UpdateFunctionConfigurationInput{}
+	if err := merge.Merge(create, update); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName:      live.FunctionName,
+		Handler:           live.Handler,
+		MemorySize:        live.MemorySize,
+		KMSKeyArn:         live.KMSKeyArn,
+		EphemeralStorage:  live.EphemeralStorage,
+		FileSystemConfigs: live.FileSystemConfigs,
+	}
+	if !reflect.DeepEqual(update, want) {
+		t.Errorf("got update %v, want %v", update, want)
+	}
+}
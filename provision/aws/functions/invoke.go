@@ -0,0 +1,59 @@
+package functions
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/config"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/sirupsen/logrus"
+)
+
+// Invocation types accepted by Invoke
+const (
+	InvocationTypeSync   = "sync"
+	InvocationTypeAsync  = "async"
+	InvocationTypeDryRun = "dry-run"
+)
+
+var invocationTypes = map[string]string{
+	InvocationTypeSync:   lambda.InvocationTypeRequestResponse,
+	InvocationTypeAsync:  lambda.InvocationTypeEvent,
+	InvocationTypeDryRun: lambda.InvocationTypeDryRun,
+}
+
+// Invoke invokes the deployed function with the given name with the payload.
+// The qualifier defaults to the function's stage alias. The log tail is requested for sync invocations.
+func Invoke(name string, payload []byte, invocationType string, qualifier string) (*lambda.InvokeOutput, error) {
+	function, ok := config.GetStringMapSub("serverless.functions", true)[name]
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", name)
+	}
+	lambdaInvocationType, ok := invocationTypes[invocationType]
+	if !ok {
+		return nil, fmt.Errorf("invalid invocation type %s. expected one of sync, async, dry-run", invocationType)
+	}
+
+	functionName := FunctionName(name, function)
+	if qualifier == "" {
+		qualifier = function.GetString("stage")
+	}
+
+	input := &lambda.InvokeInput{
+		FunctionName:   &functionName,
+		Payload:        payload,
+		InvocationType: &lambdaInvocationType,
+	}
+	if qualifier != "" {
+		input.Qualifier = &qualifier
+	}
+	if lambdaInvocationType == lambda.InvocationTypeRequestResponse {
+		input.LogType = aws.String(lambda.LogTypeTail)
+	}
+
+	logrus.Infof("invoking %s:%s (%s)", functionName, qualifier, invocationType)
+
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+	return lambdaSvc.Invoke(input)
+}
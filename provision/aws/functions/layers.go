@@ -0,0 +1,341 @@
+package functions
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/utils"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/niranjan94/bifrost/utils/debug"
+	"github.com/niranjan94/bifrost/utils/docker"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// layerHashPrefix marks the content hash in the description of published layer versions
+const layerHashPrefix = "bifrost:"
+
+// layerBuildScriptTemplate is the template for the script that builds a layer within the container.
+// The contents of python layers are expected in a python directory.
+const layerBuildScriptTemplate string = `
+#!/bin/sh
+
+rm -rf {{.BuildPath}} {{.PackageFile}}
+mkdir -p {{.BuildPath}}/python
+cp -rf {{.SourcePath}}/. {{.BuildPath}}/python
+
+if [ -f {{.RequirementsFile}} ]; then
+	cd {{.BuildPath}} && pip install -r {{.RequirementsFile}} -t python
+fi
+
+cd {{.BuildPath}} && zip -r9 {{.PackageFile}} .
+
+{{if .ShouldCleanup}}
+	rm -rf {{.BuildPath}}
+	rm -- "$0"
+{{end}}
+`
+
+// layerVersionArns caches the version ARNs of layers by name
+var layerVersionArns = map[string]string{}
+
+// LayerName returns the name a layer is published as.
+// It defaults to serverless.prefix + name + serverless.suffix.
+func LayerName(name string, layer *viper.Viper) string {
+	layer.SetDefault("name", config.GetString("serverless.prefix")+name+config.GetString("serverless.suffix"))
+	return layer.GetString("name")
+}
+
+// getLayers returns the config of every layer in serverless.layers
+func getLayers() map[string]*viper.Viper {
+	layers := map[string]*viper.Viper{}
+	for name := range config.GetStringMap("serverless.layers") {
+		layer := viper.New()
+		utils.Must(layer.MergeConfigMap(config.GetStringMap("serverless.layers." + name)))
+		layer.SetDefault("source", name)
+		layer.SetDefault("requirementsFile", config.GetString("serverless.package.RequirementsFile"))
+		layers[name] = layer
+	}
+	return layers
+}
+
+// LayersOf returns the names of the layers in serverless.layers that the deployment packages use
+func LayersOf(deploymentPackages []*DeploymentPackage) []string {
+	layers := getLayers()
+	var names []string
+	for _, deploymentPackage := range deploymentPackages {
+		for _, name := range deploymentPackage.Config.GetStringSlice("layers") {
+			if _, ok := layers[name]; ok && !utils.StringSliceContains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// layerHash returns a hash of the source directory, requirements file and compatible runtimes of a layer
+func layerHash(sourceDir string, requirementsFile string, runtimes []string) (string, error) {
+	h := sha1.New()
+	_, _ = io.WriteString(h, strings.Join(runtimes, ","))
+	if contents, err := ioutil.ReadFile(requirementsFile); err == nil {
+		_, _ = h.Write(contents)
+	}
+	err := filepath.Walk(sourceDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(sourceDir, p)
+		if err != nil {
+			return err
+		}
+		_, _ = io.WriteString(h, filepath.ToSlash(relativePath))
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// latestLayerVersion returns the most recent published version of a layer, or nil if there is none
+func latestLayerVersion(lambdaSvc *lambda.Lambda, layerName string) (*lambda.LayerVersionsListItem, error) {
+	output, err := lambdaSvc.ListLayerVersions(&lambda.ListLayerVersionsInput{
+		LayerName: &layerName,
+		MaxItems:  aws.Int64(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.LayerVersions) == 0 {
+		return nil, nil
+	}
+	return output.LayerVersions[0], nil
+}
+
+// buildLayer builds the package of a layer within the build environment of its first compatible runtime
+func buildLayer(name string, layer *viper.Viper, packageFile string) error {
+	runtimes := layer.GetStringSlice("runtimes")
+	if len(runtimes) == 0 {
+		return fmt.Errorf("layer %s has no runtimes", name)
+	}
+	for _, runtime := range runtimes {
+		if !utils.StringSliceContains(config.LayerRuntimes, runtime) {
+			return fmt.Errorf("layer %s has runtime %s. layers can only be built for %s", name, runtime, strings.Join(config.LayerRuntimes, ", "))
+		}
+	}
+
+	rootDir, buildDir, packageDir := getDirectories(containerBase, false)
+	_, localBuildDir, localPackageDir := getDirectories(utils.GetCwd(), false)
+	if err := os.MkdirAll(filepath.Join(localPackageDir, "layers"), 0751); err != nil {
+		return err
+	}
+
+	sourcePath := path.Join(rootDir, layer.GetString("source"))
+	input := BuildScriptInput{
+		RootDir:          rootDir,
+		BuildDir:         buildDir,
+		BuildPath:        path.Join(buildDir, "layers", name),
+		PackageDir:       packageDir,
+		SourcePath:       sourcePath,
+		PackageFile:      path.Join(packageDir, "layers", filepath.Base(packageFile)),
+		RequirementsFile: path.Join(sourcePath, layer.GetString("requirementsFile")),
+		ShouldCleanup:    config.GetBool("serverless.package.cleanup"),
+	}
+
+	timeout := time.Duration(config.GetInt("serverless.package.buildTimeout")) * time.Second
+	var output string
+	var err error
+
+	if BuildMode() == BuildModeNative {
+		interpreter := Interpreter(runtimes[0], nil)
+		warnNativePlatform(runtimes[0], interpreter)
+		logrus.Info("building layer ", name)
+		output, err = buildNative(input, "python", interpreter, timeout)
+	} else {
+		var buildScript bytes.Buffer
+		if err := template.Must(template.New("layerBuildScriptTemplate").Parse(layerBuildScriptTemplate)).Execute(&buildScript, input); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(localBuildDir, "build-layer.sh"), buildScript.Bytes(), 0711); err != nil {
+			return err
+		}
+
+		image := BuildImage(runtimes[0], nil)
+		var container *docker.Container
+		if container, err = getContainerFor(runtimes[0], image); err != nil {
+			return err
+		}
+
+		logrus.Info("building layer ", name)
+		output, err = runBuild(buildEnvironment(runtimes[0], image), container, []string{"/bin/sh", path.Join(buildDir, "build-layer.sh")}, timeout)
+	}
+
+	if err != nil {
+		if err == errBuildTimeout {
+			logrus.Errorf("build of layer %s timed out after %s. output until then:", name, timeout)
+			debug.PrintMultilineOutput(output)
+			removePartialBuild(filepath.Join(localBuildDir, "layers", name), packageFile)
+			return err
+		}
+		if utils.Context().Err() != nil {
+			logrus.Warnf("interrupted while building layer %s. removing its partial build", name)
+			// the build script keeps writing until its container is removed
+			removeContainers()
+			removePartialBuild(
+				filepath.Join(localBuildDir, "layers", name),
+				packageFile,
+				filepath.Join(localBuildDir, "build-layer.sh"),
+			)
+			return err
+		}
+		debug.PrintMultilineOutput(output)
+		return err
+	}
+	logrus.Info("built layer ", name)
+	return nil
+}
+
+// PublishLayers builds the layers with the given names and publishes a new version of those whose content changed
+// since their latest version. The version ARNs are used for the functions that reference the layers by name.
+func PublishLayers(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	defer removeContainers()
+
+	layers := getLayers()
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+	rootDir, _, localPackageDir := getDirectories(utils.GetCwd(), false)
+
+	for _, name := range names {
+		layer, ok := layers[name]
+		if !ok {
+			return fmt.Errorf("layer %s not found", name)
+		}
+		layerName := LayerName(name, layer)
+		sourceDir := filepath.Join(rootDir, layer.GetString("source"))
+		runtimes := layer.GetStringSlice("runtimes")
+
+		hash, err := layerHash(sourceDir, filepath.Join(sourceDir, layer.GetString("requirementsFile")), runtimes)
+		if err != nil {
+			return err
+		}
+
+		latest, err := latestLayerVersion(lambdaSvc, layerName)
+		if err != nil {
+			return err
+		}
+		if latest != nil && strings.Contains(aws.StringValue(latest.Description), layerHashPrefix+hash) {
+			logrus.Infof("layer %s is unchanged since version %d", layerName, aws.Int64Value(latest.Version))
+			layerVersionArns[name] = aws.StringValue(latest.LayerVersionArn)
+			continue
+		}
+
+		packageFile := filepath.Join(localPackageDir, "layers", name+".zip")
+		if err := buildLayer(name, layer, packageFile); err != nil {
+			return err
+		}
+
+		if viper.GetBool("dryRun") {
+			logrus.Warn("dry run mode. skipping publish.")
+			if latest != nil {
+				layerVersionArns[name] = aws.StringValue(latest.LayerVersionArn)
+			}
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(packageFile)
+		if err != nil {
+			return err
+		}
+		description := strings.TrimSpace(layer.GetString("description") + " " + layerHashPrefix + hash)
+		published, err := lambdaSvc.PublishLayerVersion(&lambda.PublishLayerVersionInput{
+			LayerName:          &layerName,
+			Description:        &description,
+			CompatibleRuntimes: aws.StringSlice(runtimes),
+			Content:            &lambda.LayerVersionContentInput{ZipFile: contents},
+		})
+		if err != nil {
+			return err
+		}
+		logrus.Infof("published layer %s version %d", layerName, aws.Int64Value(published.Version))
+		layerVersionArns[name] = aws.StringValue(published.LayerVersionArn)
+	}
+	return nil
+}
+
+// LayerVersionArns resolves the names of layers in serverless.layers to the ARNs of their latest versions.
+// Other values are expected to be layer version ARNs and returned as is.
+func LayerVersionArns(names []string) ([]string, error) {
+	layers := getLayers()
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+
+	var arns []string
+	for _, name := range names {
+		layer, ok := layers[name]
+		if !ok {
+			arns = append(arns, name)
+			continue
+		}
+		if _, ok := layerVersionArns[name]; !ok {
+			latest, err := latestLayerVersion(lambdaSvc, LayerName(name, layer))
+			if err != nil {
+				return nil, err
+			}
+			if latest == nil && viper.GetBool("dryRun") {
+				logrus.Warnf("layer %s has not been published. dry run mode. skipping.", name)
+				continue
+			}
+			if latest == nil {
+				return nil, fmt.Errorf("layer %s has not been published", name)
+			}
+			layerVersionArns[name] = aws.StringValue(latest.LayerVersionArn)
+		}
+		arns = append(arns, layerVersionArns[name])
+	}
+	return arns, nil
+}
+
+// unversionedLayerArn returns the ARN of a layer version without the version
+func unversionedLayerArn(layerVersionArn string) string {
+	if idx := strings.LastIndex(layerVersionArn, ":"); idx >= 0 {
+		return layerVersionArn[:idx]
+	}
+	return layerVersionArn
+}
+
+// mergeLayers adds the layer versions to the layers a function already has,
+// replacing other versions of the same layers
+func mergeLayers(existing []*string, layerVersionArns []string) []*string {
+	replaced := map[string]bool{}
+	for _, layerVersionArn := range layerVersionArns {
+		replaced[unversionedLayerArn(layerVersionArn)] = true
+	}
+	var layers []*string
+	for _, layerVersionArn := range existing {
+		if !replaced[unversionedLayerArn(aws.StringValue(layerVersionArn))] {
+			layers = append(layers, layerVersionArn)
+		}
+	}
+	return append(layers, aws.StringSlice(layerVersionArns)...)
+}
@@ -0,0 +1,217 @@
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/utils"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/sirupsen/logrus"
+)
+
+// logsPollInterval is how often new log events are fetched when following logs
+const logsPollInterval = 2 * time.Second
+
+// logStreamVersionRegex matches the version in the name of a lambda log stream (yyyy/mm/dd/[version]id)
+var logStreamVersionRegex = regexp.MustCompile(`^\d{4}/\d{2}/\d{2}/\[([^\]]+)\]`)
+
+var versionRegex = regexp.MustCompile(`^\d+$`)
+
+// LogsOptions controls which log events Logs fetches
+type LogsOptions struct {
+	// Since is how far back to fetch log events from
+	Since time.Duration
+	// Follow keeps polling for new log events
+	Follow bool
+	// FilterPattern is a CloudWatch Logs filter pattern the events must match
+	FilterPattern string
+	// Qualifier limits the events to a version, or to the versions an alias routes to
+	Qualifier string
+	// StageAlias limits the events to the versions the stage alias of each function routes to
+	StageAlias bool
+}
+
+// LogEvent is a log event of a function
+type LogEvent struct {
+	Function  string
+	Timestamp time.Time
+	Stream    string
+	Message   string
+}
+
+// logSource is the log group of a function and the position it has been read up to
+type logSource struct {
+	name      string
+	logGroup  string
+	versions  map[string]bool
+	startTime int64
+	seen      map[string]bool
+}
+
+// LogGroupName returns the CloudWatch Logs group lambda writes the logs of a function to
+func LogGroupName(functionName string) string {
+	return "/aws/lambda/" + functionName
+}
+
+// qualifiedVersions returns the versions a qualifier refers to.
+// An alias refers to its version and the additional versions of its weighted routing config.
+func qualifiedVersions(lambdaSvc *lambda.Lambda, functionName string, qualifier string) (map[string]bool, error) {
+	if qualifier == "$LATEST" || versionRegex.MatchString(qualifier) {
+		return map[string]bool{qualifier: true}, nil
+	}
+	alias, err := lambdaSvc.GetAlias(&lambda.GetAliasInput{
+		FunctionName: &functionName,
+		Name:         &qualifier,
+	})
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]bool{aws.StringValue(alias.FunctionVersion): true}
+	if alias.RoutingConfig != nil {
+		for version := range alias.RoutingConfig.AdditionalVersionWeights {
+			versions[version] = true
+		}
+	}
+	return versions, nil
+}
+
+// Logs fetches the log events of the functions with the given names (or all functions if none are given)
+// and passes them to handle in order of their timestamps. If options.Follow is set, it keeps polling for new events.
+func Logs(names []string, options LogsOptions, handle func(*LogEvent)) error {
+	functions := config.GetStringMapSub("serverless.functions", true)
+	if len(names) == 0 {
+		for name := range functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+	logsSvc := cloudwatchlogs.New(awsutils.GetSession(), awsutils.ServiceConfig("logs"))
+
+	startTime := time.Now().Add(-options.Since).UnixNano() / int64(time.Millisecond)
+	var sources []*logSource
+	for _, name := range names {
+		function, ok := functions[name]
+		if !ok {
+			return fmt.Errorf("function %s not found", name)
+		}
+		functionName := FunctionName(name, function)
+		source := &logSource{
+			name:      name,
+			logGroup:  LogGroupName(functionName),
+			startTime: startTime,
+			seen:      map[string]bool{},
+		}
+		qualifier := options.Qualifier
+		if qualifier == "" && options.StageAlias {
+			qualifier = function.GetString("stage")
+		}
+		if qualifier != "" {
+			versions, err := qualifiedVersions(lambdaSvc, functionName, qualifier)
+			if err != nil {
+				return err
+			}
+			source.versions = versions
+		}
+		logrus.Debugf("reading %s from %s", name, source.logGroup)
+		sources = append(sources, source)
+	}
+
+	for {
+		var events []*LogEvent
+		for _, source := range sources {
+			sourceEvents, err := source.fetch(logsSvc, options.FilterPattern)
+			if err != nil {
+				if utils.Context().Err() != nil {
+					return nil
+				}
+				return err
+			}
+			events = append(events, sourceEvents...)
+		}
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		})
+		for _, event := range events {
+			handle(event)
+		}
+		if !options.Follow {
+			return nil
+		}
+		if err := utils.Sleep(utils.Context(), logsPollInterval); err != nil {
+			// following stops on interrupt
+			return nil
+		}
+	}
+}
+
+// fetch returns the events of the log group since the last fetch.
+// Events at the last seen timestamp are fetched again and skipped if they were already seen.
+func (s *logSource) fetch(logsSvc *cloudwatchlogs.CloudWatchLogs, filterPattern string) ([]*LogEvent, error) {
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: &s.logGroup,
+		StartTime:    &s.startTime,
+	}
+	if filterPattern != "" {
+		input.FilterPattern = &filterPattern
+	}
+
+	var events []*LogEvent
+	lastTimestamp := s.startTime
+	seen := map[string]bool{}
+	err := logsSvc.FilterLogEventsPages(input, func(output *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+		for _, event := range output.Events {
+			timestamp := aws.Int64Value(event.Timestamp)
+			eventId := aws.StringValue(event.EventId)
+			if timestamp > lastTimestamp {
+				lastTimestamp = timestamp
+				seen = map[string]bool{}
+			}
+			if timestamp == lastTimestamp {
+				seen[eventId] = true
+			}
+			if s.seen[eventId] {
+				continue
+			}
+			stream := aws.StringValue(event.LogStreamName)
+			if s.versions != nil {
+				matches := logStreamVersionRegex.FindStringSubmatch(stream)
+				if matches == nil || !s.versions[matches[1]] {
+					continue
+				}
+			}
+			events = append(events, &LogEvent{
+				Function:  s.name,
+				Timestamp: time.Unix(0, timestamp*int64(time.Millisecond)),
+				Stream:    stream,
+				Message:   aws.StringValue(event.Message),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == cloudwatchlogs.ErrCodeResourceNotFoundException {
+			logrus.Debugf("%s has no logs yet", s.name)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if lastTimestamp > s.startTime {
+		s.startTime = lastTimestamp
+		s.seen = seen
+	} else {
+		for eventId := range seen {
+			s.seen[eventId] = true
+		}
+	}
+	return events, nil
+}
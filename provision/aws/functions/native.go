@@ -0,0 +1,284 @@
+package functions
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"time"
+
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/niranjan94/bifrost/utils/debug"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Build modes decide where functions and layers are built
+const (
+	BuildModeDocker = "docker"
+	BuildModeNative = "native"
+)
+
+// lambdaPlatform is the platform of the lambda execution environment
+const lambdaPlatform = "linux/amd64"
+
+// checkedInterpreters remembers the interpreters whose version was compared with a runtime
+var checkedInterpreters = map[string]bool{}
+
+// BuildMode returns the build mode given by --build-mode, then serverless.package.buildMode.
+// It defaults to docker.
+func BuildMode() string {
+	if mode := viper.GetString("build-mode"); mode != "" {
+		return mode
+	}
+	if mode := config.GetString("serverless.package.buildMode"); mode != "" {
+		return mode
+	}
+	return BuildModeDocker
+}
+
+// Interpreter returns the python interpreter native builds of the runtime install requirements with.
+// It is the function's interpreter if set, then serverless.package.interpreter,
+// then the interpreter named after the runtime (e.g. python3.9) if it is on the PATH, then python3.
+// function may be nil.
+func Interpreter(runtime string, function *viper.Viper) string {
+	if function != nil && function.GetString("interpreter") != "" {
+		return function.GetString("interpreter")
+	}
+	if interpreter := config.GetString("serverless.package.interpreter"); interpreter != "" {
+		return interpreter
+	}
+	if strings.HasPrefix(runtime, "python") {
+		if _, err := exec.LookPath(runtime); err == nil {
+			return runtime
+		}
+	}
+	return "python3"
+}
+
+// warnNativePlatform warns once per interpreter and runtime when packages built natively may not run on lambda,
+// i.e. when the host is not linux/amd64 or the interpreter's version differs from the runtime's
+func warnNativePlatform(runtime string, interpreter string) {
+	if len(checkedInterpreters) == 0 {
+		if platform := goruntime.GOOS + "/" + goruntime.GOARCH; platform != lambdaPlatform {
+			logrus.Warnf(
+				"building natively on %s. dependencies with native code will not run on lambda (%s)",
+				platform, lambdaPlatform,
+			)
+		}
+	}
+	if checkedInterpreters[interpreter+runtime] {
+		return
+	}
+	checkedInterpreters[interpreter+runtime] = true
+
+	output, err := exec.Command(interpreter, "-c", "import sys; print('python%d.%d' % sys.version_info[:2])").Output()
+	if err != nil {
+		logrus.Warnf("could not determine the version of %s: %s", interpreter, err)
+		return
+	}
+	if version := strings.TrimSpace(string(output)); strings.HasPrefix(runtime, "python") && version != runtime {
+		logrus.Warnf("building %s natively with %s, which is %s", runtime, interpreter, version)
+	}
+}
+
+// hostPath returns the path on the host of a path within the build container
+func hostPath(containerPath string) string {
+	return filepath.Join(utils.GetCwd(), filepath.FromSlash(strings.TrimPrefix(containerPath, containerBase)))
+}
+
+// copyTree copies the file or directory src to dst, like cp -rL.
+// Symbolic links are followed, as relative links would not resolve within the build path.
+func copyTree(src string, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dst {
+			// dst is within src, e.g. the build directory within a source at the root directory
+			return filepath.SkipDir
+		}
+		relativePath, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relativePath)
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			resolved, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return err
+			}
+			if info, err = os.Stat(resolved); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return copyTree(resolved, target)
+			}
+			p = resolved
+		}
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+
+		source, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+		destination, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(destination, source); err != nil {
+			destination.Close()
+			return err
+		}
+		return destination.Close()
+	})
+}
+
+// zipDirectory writes the contents of dir to a zip archive at packageFile, like zip -r9.
+// Symbolic links are followed. The archive is removed if it could not be written completely.
+func zipDirectory(dir string, packageFile string) error {
+	if err := os.MkdirAll(filepath.Dir(packageFile), 0751); err != nil {
+		return err
+	}
+	f, err := os.Create(packageFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	archive := zip.NewWriter(f)
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if info, err = os.Stat(p); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				logrus.Debugf("not following the symbolic link to the directory %s", p)
+				return nil
+			}
+		}
+		relativePath, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relativePath)
+		if info.IsDir() {
+			header.Name += "/"
+			_, err = archive.CreateHeader(header)
+			return err
+		}
+		header.Method = zip.Deflate
+
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		contents, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer contents.Close()
+		_, err = io.Copy(writer, contents)
+		return err
+	})
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(packageFile)
+		return err
+	}
+	return nil
+}
+
+// buildNative runs the steps of a build script on the host instead of a container: the source is copied into
+// contentDir within the build path, the requirements are installed into it with the interpreter's pip, the global
+// includes are copied into it, and the build path is zipped into the package file.
+// The pip installs are killed after the timeout if non-zero.
+func buildNative(input BuildScriptInput, contentDir string, interpreter string, timeout time.Duration) (string, error) {
+	ctx := utils.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// stopped returns why the build was stopped, or nil if it was not
+	stopped := func() error {
+		if utils.Context().Err() == nil && ctx.Err() == context.DeadlineExceeded {
+			return errBuildTimeout
+		}
+		return ctx.Err()
+	}
+
+	buildPath := hostPath(input.BuildPath)
+	contentPath := filepath.Join(buildPath, contentDir)
+	if err := os.RemoveAll(buildPath); err != nil {
+		return "", err
+	}
+	if err := copyTree(hostPath(input.SourcePath), contentPath); err != nil {
+		return "", err
+	}
+
+	var output bytes.Buffer
+	for _, requirementsFile := range append(append([]string{}, input.GlobalRequirements...), input.RequirementsFile) {
+		requirementsFile = hostPath(requirementsFile)
+		if _, err := os.Stat(requirementsFile); os.IsNotExist(err) {
+			logrus.Debugf("%s does not exist. skipping", requirementsFile)
+			continue
+		}
+		cmd := exec.CommandContext(ctx, interpreter, "-m", "pip", "install", "-r", requirementsFile, "-t", contentPath)
+		cmd.Dir = contentPath
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			if err := stopped(); err != nil {
+				return output.String(), err
+			}
+			return output.String(), fmt.Errorf("installing %s failed: %s", requirementsFile, err)
+		}
+	}
+	if viper.GetBool("verbose") {
+		debug.PrintMultilineOutput(output.String())
+	}
+
+	for _, include := range input.GlobalIncludes {
+		include = hostPath(include)
+		if err := copyTree(include, filepath.Join(contentPath, filepath.Base(include))); err != nil {
+			return output.String(), err
+		}
+	}
+
+	if err := stopped(); err != nil {
+		return output.String(), err
+	}
+	if err := zipDirectory(buildPath, hostPath(input.PackageFile)); err != nil {
+		return output.String(), err
+	}
+
+	if input.ShouldCleanup {
+		if err := os.RemoveAll(buildPath); err != nil {
+			return output.String(), err
+		}
+	}
+	return output.String(), nil
+}
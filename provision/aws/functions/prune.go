@@ -0,0 +1,122 @@
+package functions
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/config"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// publishedVersions returns the published versions of a function, newest first
+func publishedVersions(lambdaSvc *lambda.Lambda, functionName string) ([]int, error) {
+	var versions []int
+	input := &lambda.ListVersionsByFunctionInput{FunctionName: &functionName}
+	for {
+		output, err := lambdaSvc.ListVersionsByFunction(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range output.Versions {
+			if v, err := strconv.Atoi(aws.StringValue(version.Version)); err == nil {
+				versions = append(versions, v)
+			}
+		}
+		if output.NextMarker == nil {
+			break
+		}
+		input.Marker = output.NextMarker
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	return versions, nil
+}
+
+// aliasedVersions returns the versions any alias of a function routes to, including weighted routing
+func aliasedVersions(lambdaSvc *lambda.Lambda, functionName string) (map[string]bool, error) {
+	versions := map[string]bool{}
+	input := &lambda.ListAliasesInput{FunctionName: &functionName}
+	for {
+		output, err := lambdaSvc.ListAliases(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, alias := range output.Aliases {
+			versions[aws.StringValue(alias.FunctionVersion)] = true
+			if alias.RoutingConfig != nil {
+				for version := range alias.RoutingConfig.AdditionalVersionWeights {
+					versions[version] = true
+				}
+			}
+		}
+		if output.NextMarker == nil {
+			break
+		}
+		input.Marker = output.NextMarker
+	}
+	return versions, nil
+}
+
+// Prune deletes the published versions of the functions with the given names (or all functions if none are given)
+// except for the retain most recent ones. Versions any alias routes to are never deleted.
+func Prune(names []string, retain int) error {
+	if retain < 1 {
+		return fmt.Errorf("at least one version must be retained")
+	}
+
+	functions := config.GetStringMapSub("serverless.functions", true)
+	if len(names) == 0 {
+		for name := range functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+
+	for _, name := range names {
+		function, ok := functions[name]
+		if !ok {
+			return fmt.Errorf("function %s not found", name)
+		}
+		functionName := FunctionName(name, function)
+
+		versions, err := publishedVersions(lambdaSvc, functionName)
+		if err != nil {
+			return err
+		}
+		if len(versions) <= retain {
+			logrus.Infof("%s has %d versions. nothing to prune.", functionName, len(versions))
+			continue
+		}
+		aliased, err := aliasedVersions(lambdaSvc, functionName)
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("pruning %s to %d versions", functionName, retain)
+		for _, v := range versions[retain:] {
+			version := strconv.Itoa(v)
+			if aliased[version] {
+				logrus.Infof("keeping version %s referenced by an alias", version)
+				continue
+			}
+			logrus.Infof("deleting version %s", version)
+			if viper.GetBool("dryRun") {
+				logrus.Warn("dry run mode. skipping delete.")
+				continue
+			}
+			if _, err := lambdaSvc.DeleteFunction(&lambda.DeleteFunctionInput{
+				FunctionName: &functionName,
+				Qualifier:    &version,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,493 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/utils"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	// rolePolicyName is the name of the inline policy bifrost maintains on generated roles
+	rolePolicyName = "bifrost"
+
+	basicExecutionPolicyArn = "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"
+	vpcAccessPolicyArn      = "arn:aws:iam::aws:policy/service-role/AWSLambdaVPCAccessExecutionRole"
+
+	// maxRoleNameLength is the maximum length of IAM role names
+	maxRoleNameLength = 64
+
+	// rolePropagationDelay is how long to wait after creating a role for lambda to be able to assume it
+	rolePropagationDelay = 10 * time.Second
+
+	lambdaTrustPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"lambda.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+)
+
+// statementKeys maps the lower-cased keys of IAM statements to their canonical names
+var statementKeys = map[string]string{
+	"sid":         "Sid",
+	"effect":      "Effect",
+	"action":      "Action",
+	"notaction":   "NotAction",
+	"resource":    "Resource",
+	"notresource": "NotResource",
+	"condition":   "Condition",
+}
+
+// eventSourceActions are the actions a function needs to read from the event sources of a service
+var eventSourceActions = map[string][]string{
+	"sqs":      {"sqs:ReceiveMessage", "sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:ChangeMessageVisibility"},
+	"kinesis":  {"kinesis:GetRecords", "kinesis:GetShardIterator", "kinesis:DescribeStream", "kinesis:DescribeStreamSummary", "kinesis:ListShards", "kinesis:ListStreams", "kinesis:SubscribeToShard"},
+	"dynamodb": {"dynamodb:GetRecords", "dynamodb:GetShardIterator", "dynamodb:DescribeStream", "dynamodb:ListStreams"},
+}
+
+// attachedPolicies are the managed policies bifrost attaches to generated roles.
+// They are detached when no longer needed, unlike managed policies attached by users.
+var attachedPolicies = map[string]bool{
+	basicExecutionPolicyArn: true,
+	vpcAccessPolicyArn:      true,
+}
+
+// ensuredRoles caches the ARNs of the roles that were created or updated in this run by role name
+var ensuredRoles = map[string]string{}
+
+// RoleDifference is a difference between the desired and the live state of a generated execution role
+type RoleDifference struct {
+	Role    string
+	Key     string
+	Desired string
+	Live    string
+}
+
+// executionRole is the desired state of a generated execution role
+type executionRole struct {
+	name            string
+	functions       []string
+	policyDocument  map[string]interface{}
+	managedPolicies []string
+}
+
+// HasGeneratedRole checks if a function uses a role generated from its permissions,
+// i.e. it has permissions but no role
+func HasGeneratedRole(function *viper.Viper) bool {
+	return function.GetString("role") == "" && function.IsSet("permissions")
+}
+
+// RoleName returns the name of the generated execution role of a function.
+// It defaults to the deployed function name with a -role suffix.
+// Names longer than IAM allows are truncated and end with a hash of the full name, so that they stay unique.
+func RoleName(name string, function *viper.Viper) string {
+	roleName := function.GetString("roleName")
+	if roleName == "" {
+		roleName = FunctionName(name, function) + "-role"
+	}
+	if len(roleName) > maxRoleNameLength {
+		hash := utils.SHA1Hash(roleName)[:8]
+		roleName = roleName[:maxRoleNameLength-len(hash)-1] + "-" + hash
+	}
+	return roleName
+}
+
+// statements returns the IAM statements in the permissions of a function
+func statements(function *viper.Viper) []interface{} {
+	var list []interface{}
+	for _, item := range cast.ToSlice(function.Get("permissions")) {
+		statement := map[string]interface{}{"Effect": "Allow"}
+		for k, v := range cast.ToStringMap(item) {
+			if canonical, ok := statementKeys[strings.ToLower(k)]; ok {
+				k = canonical
+			}
+			statement[k] = normalizeValue(v)
+		}
+		list = append(list, statement)
+	}
+	return list
+}
+
+// normalizeValue converts the maps within a config value to string maps, so that it can be marshalled to JSON
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}, map[string]interface{}:
+		normalized := map[string]interface{}{}
+		for k, item := range cast.ToStringMap(v) {
+			normalized[k] = normalizeValue(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for idx, item := range v {
+			normalized[idx] = normalizeValue(item)
+		}
+		return normalized
+	}
+	return value
+}
+
+// eventSourceStatements returns statements that allow a function to read from the SQS queues, Kinesis streams
+// and DynamoDB streams it is attached to by event source mappings
+func eventSourceStatements(lambdaSvc *lambda.Lambda, functionName string) ([]interface{}, error) {
+	resources := map[string][]string{}
+	input := &lambda.ListEventSourceMappingsInput{FunctionName: &functionName}
+	for {
+		output, err := lambdaSvc.ListEventSourceMappings(input)
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == lambda.ErrCodeResourceNotFoundException {
+				return nil, nil
+			}
+			return nil, err
+		}
+		for _, mapping := range output.EventSourceMappings {
+			sourceArn, err := arn.Parse(aws.StringValue(mapping.EventSourceArn))
+			if err != nil {
+				continue
+			}
+			if _, ok := eventSourceActions[sourceArn.Service]; ok {
+				resources[sourceArn.Service] = append(resources[sourceArn.Service], sourceArn.String())
+			}
+		}
+		if output.NextMarker == nil {
+			break
+		}
+		input.Marker = output.NextMarker
+	}
+
+	var services []string
+	for service := range resources {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	var list []interface{}
+	for _, service := range services {
+		sort.Strings(resources[service])
+		list = append(list, map[string]interface{}{
+			"Effect":   "Allow",
+			"Action":   eventSourceActions[service],
+			"Resource": resources[service],
+		})
+	}
+	return list, nil
+}
+
+// desiredExecutionRole returns the desired state of a generated role from the permissions of every function using it
+func desiredExecutionRole(lambdaSvc *lambda.Lambda, roleName string) (*executionRole, error) {
+	role := &executionRole{
+		name:            roleName,
+		managedPolicies: []string{basicExecutionPolicyArn},
+	}
+	var statementList []interface{}
+	functions := config.GetStringMapSub("serverless.functions", true)
+	var names []string
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		function := functions[name]
+		if !HasGeneratedRole(function) || RoleName(name, function) != roleName {
+			continue
+		}
+		role.functions = append(role.functions, name)
+		statementList = append(statementList, statements(function)...)
+		sourceStatements, err := eventSourceStatements(lambdaSvc, FunctionName(name, function))
+		if err != nil {
+			return nil, err
+		}
+		statementList = append(statementList, sourceStatements...)
+		if function.IsSet("vpcConfig.subnetIds") && role.managedPolicies[len(role.managedPolicies)-1] != vpcAccessPolicyArn {
+			role.managedPolicies = append(role.managedPolicies, vpcAccessPolicyArn)
+		}
+	}
+
+	if len(statementList) > 0 {
+		role.policyDocument = map[string]interface{}{
+			"Version":   "2012-10-17",
+			"Statement": statementList,
+		}
+	}
+	return role, nil
+}
+
+// livePolicyDocument returns the inline policy bifrost maintains on a role, or nil if it has none
+func livePolicyDocument(iamSvc *iam.IAM, roleName string) (map[string]interface{}, error) {
+	output, err := iamSvc.GetRolePolicy(&iam.GetRolePolicyInput{
+		RoleName:   &roleName,
+		PolicyName: aws.String(rolePolicyName),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException {
+			return nil, nil
+		}
+		return nil, err
+	}
+	document, err := url.QueryUnescape(aws.StringValue(output.PolicyDocument))
+	if err != nil {
+		return nil, err
+	}
+	var policyDocument map[string]interface{}
+	if err := json.Unmarshal([]byte(document), &policyDocument); err != nil {
+		return nil, err
+	}
+	return policyDocument, nil
+}
+
+// liveManagedPolicies returns the ARNs of the managed policies attached to a role
+func liveManagedPolicies(iamSvc *iam.IAM, roleName string) ([]string, error) {
+	var policies []string
+	err := iamSvc.ListAttachedRolePoliciesPages(
+		&iam.ListAttachedRolePoliciesInput{RoleName: &roleName},
+		func(output *iam.ListAttachedRolePoliciesOutput, lastPage bool) bool {
+			for _, policy := range output.AttachedPolicies {
+				policies = append(policies, aws.StringValue(policy.PolicyArn))
+			}
+			return true
+		},
+	)
+	return policies, err
+}
+
+// toJSON returns the JSON of a value, or an empty string for nil
+func toJSON(value interface{}) string {
+	if value == nil || reflect.ValueOf(value).IsNil() {
+		return ""
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// sameDocument checks if two policy documents are equal, ignoring formatting
+func sameDocument(desired map[string]interface{}, live map[string]interface{}) bool {
+	var normalizedDesired map[string]interface{}
+	if desired != nil {
+		_ = json.Unmarshal([]byte(toJSON(desired)), &normalizedDesired)
+	}
+	return reflect.DeepEqual(normalizedDesired, live)
+}
+
+// roleDifferences compares the desired state of a generated role with its live state
+func roleDifferences(iamSvc *iam.IAM, role *executionRole) ([]*RoleDifference, error) {
+	if _, err := iamSvc.GetRole(&iam.GetRoleInput{RoleName: &role.name}); err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException {
+			// the policies of a missing role are created along with it
+			differences := []*RoleDifference{{Role: role.name, Key: "role", Desired: role.name}}
+			if role.policyDocument != nil {
+				differences = append(differences, &RoleDifference{Role: role.name, Key: "policy " + rolePolicyName, Desired: toJSON(role.policyDocument)})
+			}
+			for _, policyArn := range role.managedPolicies {
+				differences = append(differences, &RoleDifference{Role: role.name, Key: "managed policy", Desired: policyArn})
+			}
+			return differences, nil
+		}
+		return nil, err
+	}
+
+	var differences []*RoleDifference
+	livePolicy, err := livePolicyDocument(iamSvc, role.name)
+	if err != nil {
+		return nil, err
+	}
+	if !sameDocument(role.policyDocument, livePolicy) {
+		differences = append(differences, &RoleDifference{
+			Role:    role.name,
+			Key:     "policy " + rolePolicyName,
+			Desired: toJSON(role.policyDocument),
+			Live:    toJSON(livePolicy),
+		})
+	}
+
+	livePolicies, err := liveManagedPolicies(iamSvc, role.name)
+	if err != nil {
+		return nil, err
+	}
+	live := map[string]bool{}
+	for _, policyArn := range livePolicies {
+		live[policyArn] = true
+	}
+	desired := map[string]bool{}
+	for _, policyArn := range role.managedPolicies {
+		desired[policyArn] = true
+		if !live[policyArn] {
+			differences = append(differences, &RoleDifference{Role: role.name, Key: "managed policy", Desired: policyArn})
+		}
+	}
+	for _, policyArn := range livePolicies {
+		if desired[policyArn] {
+			continue
+		}
+		// managed policies attached by users are kept
+		if !attachedPolicies[policyArn] {
+			logrus.Debugf("role %s has managed policy %s attached outside of bifrost", role.name, policyArn)
+			continue
+		}
+		differences = append(differences, &RoleDifference{Role: role.name, Key: "managed policy", Live: policyArn})
+	}
+	return differences, nil
+}
+
+// ExecutionRoleDrift compares the generated execution role of a function with its live state
+func ExecutionRoleDrift(name string, function *viper.Viper) ([]*RoleDifference, error) {
+	if !HasGeneratedRole(function) {
+		return nil, nil
+	}
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+	iamSvc := iam.New(awsutils.GetSession(), awsutils.ServiceConfig("iam"))
+	role, err := desiredExecutionRole(lambdaSvc, RoleName(name, function))
+	if err != nil {
+		return nil, err
+	}
+	return roleDifferences(iamSvc, role)
+}
+
+// EnsureExecutionRole creates or updates the generated execution role of a function and returns its ARN.
+// Newly created roles are waited on until lambda can assume them.
+func EnsureExecutionRole(name string, function *viper.Viper) (string, error) {
+	roleName := RoleName(name, function)
+	if roleArn, ok := ensuredRoles[roleName]; ok {
+		return roleArn, nil
+	}
+
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+	iamSvc := iam.New(awsutils.GetSession(), awsutils.ServiceConfig("iam"))
+
+	role, err := desiredExecutionRole(lambdaSvc, roleName)
+	if err != nil {
+		return "", err
+	}
+	differences, err := roleDifferences(iamSvc, role)
+	if err != nil {
+		return "", err
+	}
+
+	created := false
+	var roleArn string
+	for _, d := range differences {
+		if d.Key == "role" {
+			created = true
+			continue
+		}
+		if d.Live != "" && d.Key != "managed policy" {
+			logrus.Warnf("role %s drifted: %s %s", d.Role, d.Key, d.Live)
+		}
+	}
+
+	if viper.GetBool("dryRun") {
+		logrus.Infof("role %s has %d differences. dry run mode. skipping update.", roleName, len(differences))
+		if created {
+			identity := awsutils.GetIdentity()
+			if identity == nil {
+				return "", fmt.Errorf("could not determine the account of role %s", roleName)
+			}
+			roleArn = fmt.Sprintf("arn:aws:iam::%s:role/%s", aws.StringValue(identity.Account), roleName)
+		}
+	}
+
+	if created && !viper.GetBool("dryRun") {
+		logrus.Infof("creating role %s for %s", roleName, strings.Join(role.functions, ", "))
+		output, err := iamSvc.CreateRole(&iam.CreateRoleInput{
+			RoleName:                 &roleName,
+			AssumeRolePolicyDocument: aws.String(lambdaTrustPolicy),
+			Description:              aws.String("Execution role of " + strings.Join(role.functions, ", ")),
+			Tags:                     []*iam.Tag{{Key: aws.String("bifrost:managed"), Value: aws.String("true")}},
+		})
+		if err != nil {
+			return "", err
+		}
+		roleArn = aws.StringValue(output.Role.Arn)
+	} else if !created {
+		output, err := iamSvc.GetRole(&iam.GetRoleInput{RoleName: &roleName})
+		if err != nil {
+			return "", err
+		}
+		roleArn = aws.StringValue(output.Role.Arn)
+	}
+
+	// only what differs is updated, so that up-to-date roles are left alone
+	for _, d := range differences {
+		if viper.GetBool("dryRun") {
+			break
+		}
+		var err error
+		switch {
+		case d.Key == "role":
+		case d.Key == "managed policy" && d.Desired != "":
+			logrus.Infof("attaching %s to role %s", d.Desired, roleName)
+			_, err = iamSvc.AttachRolePolicy(&iam.AttachRolePolicyInput{
+				RoleName:  &roleName,
+				PolicyArn: aws.String(d.Desired),
+			})
+		case d.Key == "managed policy":
+			logrus.Infof("detaching %s from role %s", d.Live, roleName)
+			_, err = iamSvc.DetachRolePolicy(&iam.DetachRolePolicyInput{
+				RoleName:  &roleName,
+				PolicyArn: aws.String(d.Live),
+			})
+		case role.policyDocument != nil:
+			logrus.Infof("updating policy %s of role %s", rolePolicyName, roleName)
+			_, err = iamSvc.PutRolePolicy(&iam.PutRolePolicyInput{
+				RoleName:       &roleName,
+				PolicyName:     aws.String(rolePolicyName),
+				PolicyDocument: aws.String(toJSON(role.policyDocument)),
+			})
+		default:
+			logrus.Infof("deleting policy %s of role %s", rolePolicyName, roleName)
+			_, err = iamSvc.DeleteRolePolicy(&iam.DeleteRolePolicyInput{
+				RoleName:   &roleName,
+				PolicyName: aws.String(rolePolicyName),
+			})
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException {
+				err = nil
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if created && !viper.GetBool("dryRun") {
+		logrus.Infof("waiting for role %s to propagate", roleName)
+		if err := iamSvc.WaitUntilRoleExistsWithContext(utils.Context(), &iam.GetRoleInput{RoleName: &roleName}); err != nil {
+			return "", err
+		}
+		// lambda fails to assume roles for a few seconds after they exist
+		if err := utils.Sleep(utils.Context(), rolePropagationDelay); err != nil {
+			return "", err
+		}
+	}
+
+	ensuredRoles[roleName] = roleArn
+	return roleArn, nil
+}
+
+// createFunction creates a function, retrying while lambda cannot assume its role yet as IAM changes propagate
+func createFunction(lambdaSvc *lambda.Lambda, input *lambda.CreateFunctionInput) (*lambda.FunctionConfiguration, error) {
+	delay := 2 * time.Second
+	for attempt := 1; ; attempt++ {
+		output, err := lambdaSvc.CreateFunction(input)
+		awsErr, ok := err.(awserr.Error)
+		if !ok || attempt == 5 || awsErr.Code() != lambda.ErrCodeInvalidParameterValueException ||
+			!strings.Contains(awsErr.Message(), "cannot be assumed") {
+			return output, err
+		}
+		logrus.Infof("role %s cannot be assumed yet. retrying in %s", aws.StringValue(input.Role), delay)
+		if err := utils.Sleep(utils.Context(), delay); err != nil {
+			return nil, err
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/spf13/viper"
+)
+
+func TestRoleName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	tests := []struct {
+		name     string
+		roleName string
+		want     string
+	}{
+		{name: "hello", want: "hello-role"},
+		{name: "hello", roleName: "shared-role", want: "shared-role"},
+		{name: strings.Repeat("a", 59), want: strings.Repeat("a", 59) + "-role"},
+		{name: long, want: strings.Repeat("a", 55) + "-" + utils.SHA1Hash(long + "-role")[:8]},
+	}
+	for _, test := range tests {
+		function := viper.New()
+		function.Set("prefix", "")
+		function.Set("suffix", "")
+		function.Set("roleName", test.roleName)
+		if got := RoleName(test.name, function); got != test.want {
+			t.Errorf("RoleName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	// truncated names that only differ after the 64th character stay unique
+	first, second := viper.New(), viper.New()
+	first.Set("roleName", long+"-first")
+	second.Set("roleName", long+"-second")
+	if a, b := RoleName("a", first), RoleName("b", second); a == b || len(a) != maxRoleNameLength {
+		t.Errorf("RoleName = %q and %q, want unique names of %d characters", a, b, maxRoleNameLength)
+	}
+}
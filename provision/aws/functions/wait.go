@@ -0,0 +1,61 @@
+package functions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	updatePollDelay    = time.Second
+	updatePollMaxDelay = 15 * time.Second
+	updateTimeout      = 10 * time.Minute
+)
+
+// waitForFunctionUpdate polls the configuration of a function with backoff until it is no longer pending
+// and its last update is no longer in progress, and returns that configuration.
+// A failed creation or update is returned as an error with the reason lambda gives.
+func waitForFunctionUpdate(lambdaSvc *lambda.Lambda, functionName *string) (*lambda.FunctionConfiguration, error) {
+	deadline := time.Now().Add(updateTimeout)
+	delay := updatePollDelay
+	for {
+		configuration, err := lambdaSvc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+			FunctionName: functionName,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		state := aws.StringValue(configuration.State)
+		lastUpdateStatus := aws.StringValue(configuration.LastUpdateStatus)
+		switch {
+		case state == lambda.StateFailed:
+			return nil, fmt.Errorf(
+				"%s failed: %s (%s)", aws.StringValue(functionName),
+				aws.StringValue(configuration.StateReason), aws.StringValue(configuration.StateReasonCode),
+			)
+		case lastUpdateStatus == lambda.LastUpdateStatusFailed:
+			return nil, fmt.Errorf(
+				"update of %s failed: %s (%s)", aws.StringValue(functionName),
+				aws.StringValue(configuration.LastUpdateStatusReason), aws.StringValue(configuration.LastUpdateStatusReasonCode),
+			)
+		case state != lambda.StatePending && lastUpdateStatus != lambda.LastUpdateStatusInProgress:
+			return configuration, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s to be updated. state: %s, last update: %s", aws.StringValue(functionName), state, lastUpdateStatus)
+		}
+		logrus.Debugf("waiting for %s. state: %s, last update: %s", aws.StringValue(functionName), state, lastUpdateStatus)
+		if err := utils.Sleep(utils.Context(), delay); err != nil {
+			return nil, err
+		}
+		if delay *= 2; delay > updatePollMaxDelay {
+			delay = updatePollMaxDelay
+		}
+	}
+}
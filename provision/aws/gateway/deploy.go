@@ -23,7 +23,7 @@ func DeployStage() error {
 	gatewaySvc := apigateway.New(awsutils.GetSession())
 	wsGatewaySvc := apigatewayv2.New(awsutils.GetSession())
 
-	stage := viper.GetString("defaults.stage")
+	stage := config.GetString("defaults.stage")
 
 	logrus.Info("deploying stage ", stage)
 
@@ -34,13 +34,13 @@ func DeployStage() error {
 
 	_, err := gatewaySvc.CreateDeployment(&apigateway.CreateDeploymentInput{
 		RestApiId: &restApiId,
-		StageName: aws.String(viper.GetString("defaults.stage")),
+		StageName: aws.String(config.GetString("defaults.stage")),
 	})
 
 	if wsApiId != "" {
 		if _, err := wsGatewaySvc.CreateDeployment(&apigatewayv2.CreateDeploymentInput{
 			ApiId: &wsApiId,
-			StageName: aws.String(viper.GetString("defaults.stage")),
+			StageName: aws.String(config.GetString("defaults.stage")),
 		}); err != nil {
 			logrus.Error(err)
 		}
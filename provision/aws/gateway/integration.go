@@ -15,6 +15,18 @@ import (
 	"strings"
 )
 
+// ResourcePath returns the full path of an `api.resources` path as used by API Gateway
+func ResourcePath(resourcePath string) string {
+	fullResourcePath := path.Join(viper.GetString("apiGateway.resourcePrefix"), resourcePath)
+	if strings.HasPrefix(resourcePath, "/") {
+		fullResourcePath = resourcePath
+	}
+	if !strings.HasPrefix(fullResourcePath, "/") {
+		fullResourcePath = "/" + fullResourcePath
+	}
+	return fullResourcePath
+}
+
 func addInvokePermission(aliasArn string, invokeArn string) error {
 	lambdaSvc := lambda.New(awsutils.GetSession())
 	statementId := utils.SHA1Hash(invokeArn)
@@ -95,10 +107,7 @@ func IntegrateFunctions(functions []*functions.DeploymentPackage) error {
 	resourcePrefix := viper.GetString("apiGateway.resourcePrefix")
 
 	getResourceByPath := func(resourcePath string) *apigateway.Resource {
-		fullResourcePath := path.Join(resourcePrefix, resourcePath)
-		if strings.HasPrefix(resourcePath, "/") {
-			fullResourcePath = resourcePath
-		}
+		fullResourcePath := ResourcePath(resourcePath)
 		for idx := range resources {
 			resource := resources[idx]
 			if *resource.Path == fullResourcePath {
@@ -1,23 +1,57 @@
 package aws
 
 import (
+	"github.com/niranjan94/bifrost/config"
 	"github.com/niranjan94/bifrost/provision/aws/cognito"
 	"github.com/niranjan94/bifrost/provision/aws/functions"
 	"github.com/niranjan94/bifrost/provision/aws/gateway"
+	"github.com/niranjan94/bifrost/utils"
 	"github.com/sirupsen/logrus"
 )
 
+// interrupted checks if bifrost was interrupted and logs the steps that are skipped because of it
+func interrupted(skipped string) bool {
+	if utils.Context().Err() == nil {
+		return false
+	}
+	logrus.Warn("interrupted. skipping ", skipped)
+	return true
+}
+
 func Provision()  {
-	deploymentPackages := functions.Deploy(
-		functions.Build(),
-	)
+	deploymentPackages := functions.Build()
+	if interrupted("the deploy") {
+		return
+	}
+	if err := functions.PublishLayers(functions.LayersOf(deploymentPackages)); err != nil {
+		logrus.Error(err)
+	}
+	if interrupted("the deploy") {
+		return
+	}
+	deploymentPackages = functions.Deploy(deploymentPackages)
+	if interrupted("the API Gateway and Cognito integrations") {
+		return
+	}
 	if err := gateway.IntegrateFunctions(deploymentPackages); err != nil {
 		logrus.Error(err)
 	}
-	if err := gateway.DeployStage(); err != nil {
+	if err := functions.WaitForProvisionedConcurrency(deploymentPackages); err != nil {
+		logrus.Error(err)
+		logrus.Error("not deploying the API Gateway stage")
+	} else if err := gateway.DeployStage(); err != nil {
 		logrus.Error(err)
 	}
 	if err := cognito.IntegrateFunctions(deploymentPackages); err != nil {
 		logrus.Error(err)
 	}
+	if retainVersions := config.GetInt("serverless.retainVersions"); retainVersions > 0 {
+		var names []string
+		for _, deploymentPackage := range deploymentPackages {
+			names = append(names, deploymentPackage.Name)
+		}
+		if err := functions.Prune(names, retainVersions); err != nil {
+			logrus.Error(err)
+		}
+	}
 }
\ No newline at end of file
@@ -0,0 +1,209 @@
+package status
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/spf13/viper"
+)
+
+// Difference is a difference between the config and the live state of a function
+type Difference struct {
+	Function string `json:"function"`
+	// Source is the AWS resource the live value is read from
+	Source  string `json:"source"`
+	Key     string `json:"key"`
+	Desired string `json:"desired"`
+	Live    string `json:"live"`
+}
+
+func (d *Difference) String() string {
+	return fmt.Sprintf("%s: %s %s: expected %q, found %q", d.Function, d.Source, d.Key, d.Desired, d.Live)
+}
+
+// differences collects the differences of a function
+type differences struct {
+	function string
+	source   string
+	list     []*Difference
+}
+
+// compare adds a difference if the desired value is set and differs from the live value
+func (d *differences) compare(key string, desired string, live string) {
+	if desired != "" && desired != live {
+		d.add(key, desired, live)
+	}
+}
+
+func (d *differences) add(key string, desired string, live string) {
+	d.list = append(d.list, &Difference{Function: d.function, Source: d.source, Key: key, Desired: desired, Live: live})
+}
+
+// sortedJoin returns the values sorted and comma separated, so that sets can be compared
+func sortedJoin(values []string) string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// configurationDifferences compares the config of a function with its live lambda configuration
+func configurationDifferences(d *differences, function *viper.Viper, configuration *lambda.FunctionConfiguration) {
+	d.compare("role", function.GetString("role"), aws.StringValue(configuration.Role))
+	d.compare("runtime", function.GetString("runtime"), aws.StringValue(configuration.Runtime))
+	d.compare("handler", function.GetString("handler"), aws.StringValue(configuration.Handler))
+	if memorySize := function.GetInt64("memorySize"); memorySize >= 128 {
+		d.compare("memorySize", fmt.Sprint(memorySize), fmt.Sprint(aws.Int64Value(configuration.MemorySize)))
+	}
+	if timeout := function.GetInt64("timeout"); timeout > 0 {
+		d.compare("timeout", fmt.Sprint(timeout), fmt.Sprint(aws.Int64Value(configuration.Timeout)))
+	}
+	if configuration.TracingConfig != nil {
+		d.compare("tracing", function.GetString("tracing"), aws.StringValue(configuration.TracingConfig.Mode))
+	}
+	liveDeadLetterTarget := ""
+	if configuration.DeadLetterConfig != nil {
+		liveDeadLetterTarget = aws.StringValue(configuration.DeadLetterConfig.TargetArn)
+	}
+	d.compare("deadLetterTarget", function.GetString("deadLetterTarget"), liveDeadLetterTarget)
+	if ephemeralStorage := function.GetInt64("ephemeralStorage"); ephemeralStorage >= 512 && configuration.EphemeralStorage != nil {
+		d.compare("ephemeralStorage", fmt.Sprint(ephemeralStorage), fmt.Sprint(aws.Int64Value(configuration.EphemeralStorage.Size)))
+	}
+
+	liveEnvironment := map[string]*string{}
+	if configuration.Environment != nil && configuration.Environment.Variables != nil {
+		liveEnvironment = configuration.Environment.Variables
+	}
+	desiredEnvironment := map[string]string{}
+	for k, v := range function.GetStringMapString("environment") {
+		desiredEnvironment[strings.ToUpper(k)] = v
+	}
+	// variables that are only set live are kept by deploys, so they are not drift
+	for k, v := range desiredEnvironment {
+		if live, ok := liveEnvironment[k]; !ok || aws.StringValue(live) != v {
+			d.add("environment."+k, v, aws.StringValue(live))
+		}
+	}
+
+	var liveSubnetIds, liveSecurityGroupIds []string
+	if configuration.VpcConfig != nil {
+		liveSubnetIds = aws.StringValueSlice(configuration.VpcConfig.SubnetIds)
+		liveSecurityGroupIds = aws.StringValueSlice(configuration.VpcConfig.SecurityGroupIds)
+	}
+	for key, live := range map[string][]string{
+		"vpcConfig.subnetIds":        liveSubnetIds,
+		"vpcConfig.securityGroupIds": liveSecurityGroupIds,
+	} {
+		if !function.IsSet(key) {
+			continue
+		}
+		if desired := sortedJoin(function.GetStringSlice(key)); desired != sortedJoin(live) {
+			d.add(key, desired, sortedJoin(live))
+		}
+	}
+}
+
+// bindingSource returns the AWS resource a binding is read from
+func bindingSource(binding *Binding) string {
+	switch binding.Kind {
+	case BindingRest, BindingAuthorizer:
+		return "rest api " + config.GetString("apiGateway.restApiId")
+	case BindingWs, BindingWsAuthorizer:
+		return "websocket api " + config.GetString("apiGateway.wsApiId")
+	case BindingCognito:
+		return "user pool " + config.GetStringMapString("cognito.userPools")[config.GetString("defaults.stage")]
+	}
+	return binding.Kind
+}
+
+// Drift compares the config of the functions with the given names (or all functions if none are given)
+// with their live lambda configuration, stage alias and bindings and returns every difference
+func Drift(names []string) ([]*Difference, error) {
+	statuses, err := Functions(names)
+	if err != nil {
+		return nil, err
+	}
+
+	functionConfigs := config.GetStringMapSub("serverless.functions", true)
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+
+	var list []*Difference
+	for _, s := range statuses {
+		if !s.Deployed {
+			list = append(list, &Difference{Function: s.Name, Source: "lambda", Key: "function", Desired: s.FunctionName})
+			continue
+		}
+
+		d := &differences{function: s.Name, source: "lambda " + s.FunctionName}
+		configuration, err := lambdaSvc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+			FunctionName: &s.FunctionName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		configurationDifferences(d, functionConfigs[s.Name], configuration)
+
+		if function := functionConfigs[s.Name]; functions.HasGeneratedRole(function) {
+			roleName := functions.RoleName(s.Name, function)
+			liveRole := aws.StringValue(configuration.Role)
+			d.compare("role", roleName, liveRole[strings.LastIndex(liveRole, "/")+1:])
+
+			roleDifferences, err := functions.ExecutionRoleDrift(s.Name, function)
+			if err != nil {
+				return nil, err
+			}
+			d.source = "role " + roleName
+			for _, roleDifference := range roleDifferences {
+				d.add(roleDifference.Key, roleDifference.Desired, roleDifference.Live)
+			}
+		}
+
+		d.source = "alias " + s.FunctionName + ":" + s.Stage
+		alias, err := lambdaSvc.GetAlias(&lambda.GetAliasInput{
+			FunctionName: &s.FunctionName,
+			Name:         &s.Stage,
+		})
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != lambda.ErrCodeResourceNotFoundException {
+				return nil, err
+			}
+			d.add("functionVersion", s.LatestVersion, "")
+		} else {
+			d.compare("functionVersion", s.LatestVersion, aws.StringValue(alias.FunctionVersion))
+			if alias.RoutingConfig != nil {
+				for version, weight := range alias.RoutingConfig.AdditionalVersionWeights {
+					d.add("routingConfig."+version, "", fmt.Sprint(aws.Float64Value(weight)))
+				}
+			}
+		}
+
+		for _, binding := range s.Bindings {
+			if binding.Drift == "" {
+				continue
+			}
+			desired := ""
+			if binding.Configured {
+				desired = s.FunctionName + ":" + gatewayQualifier
+				if binding.Kind == BindingCognito {
+					desired = s.FunctionName + ":" + s.Stage
+				}
+			}
+			d.source = bindingSource(binding)
+			d.add(binding.Kind+" "+binding.Target, desired, binding.Live)
+		}
+		list = append(list, d.list...)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Function != list[j].Function {
+			return list[i].Function < list[j].Function
+		}
+		return list[i].Source+list[i].Key < list[j].Source+list[j].Key
+	})
+	return list, nil
+}
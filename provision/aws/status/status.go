@@ -0,0 +1,455 @@
+// Package status inspects the deployed state of the functions of a stage and their bindings
+package status
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/apigatewayv2"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/niranjan94/bifrost/config"
+	"github.com/niranjan94/bifrost/provision/aws/functions"
+	"github.com/niranjan94/bifrost/provision/aws/gateway"
+	awsutils "github.com/niranjan94/bifrost/utils/aws"
+	"github.com/spf13/viper"
+)
+
+// Kinds of bindings
+const (
+	BindingRest         = "rest"
+	BindingWs           = "ws"
+	BindingAuthorizer   = "authorizer"
+	BindingWsAuthorizer = "wsAuthorizer"
+	BindingCognito      = "cognito"
+)
+
+// gatewayQualifier is the qualifier API Gateway integrations and authorizers invoke functions with
+const gatewayQualifier = "${stageVariables.lambdaAlias}"
+
+// Binding is an API Gateway resource, route, authorizer or Cognito trigger that is configured to or does invoke a function
+type Binding struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Url    string `json:"url,omitempty"`
+	// Live is the function (and qualifier) the binding invokes, if any
+	Live string `json:"live,omitempty"`
+	// Configured is true if the binding is in the config
+	Configured bool `json:"configured"`
+	// Drift describes how the live binding differs from the config. It is empty if they match.
+	Drift string `json:"drift,omitempty"`
+}
+
+// FunctionStatus is the deployed state of a function on a stage
+type FunctionStatus struct {
+	Name          string     `json:"name"`
+	FunctionName  string     `json:"functionName"`
+	Stage         string     `json:"stage"`
+	Deployed      bool       `json:"deployed"`
+	LatestVersion string     `json:"latestVersion,omitempty"`
+	AliasVersion  string     `json:"aliasVersion,omitempty"`
+	CodeSize      int64      `json:"codeSize,omitempty"`
+	LastModified  string     `json:"lastModified,omitempty"`
+	Runtime       string     `json:"runtime,omitempty"`
+	MemorySize    int64      `json:"memorySize,omitempty"`
+	Timeout       int64      `json:"timeout,omitempty"`
+	Bindings      []*Binding `json:"bindings"`
+}
+
+// HasDrift checks if any binding of the function differs from the config
+func (s *FunctionStatus) HasDrift() bool {
+	for _, binding := range s.Bindings {
+		if binding.Drift != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// liveBinding is a binding as found on AWS
+type liveBinding struct {
+	kind   string
+	target string
+	url    string
+	// uri is the lambda function ARN or the API Gateway invocation URI of the binding
+	uri string
+	// found is false for configured bindings whose resource does not exist
+	found bool
+}
+
+// key returns the key that identifies the binding across the config and AWS
+func (b *liveBinding) key() string {
+	return b.kind + " " + b.target
+}
+
+// invokedFunction returns the name and qualifier of the function a lambda ARN or API Gateway invocation URI refers to
+func invokedFunction(uri string) (name string, qualifier string) {
+	functionArn := uri
+	if idx := strings.Index(uri, "/functions/"); idx >= 0 {
+		functionArn = strings.TrimSuffix(uri[idx+len("/functions/"):], "/invocations")
+	}
+	parts := strings.SplitN(functionArn, ":", 8)
+	if len(parts) < 7 || parts[5] != "function" {
+		return "", ""
+	}
+	if len(parts) == 8 {
+		qualifier = parts[7]
+	}
+	return parts[6], qualifier
+}
+
+// liveState holds the bindings of the API Gateway APIs and the Cognito user pool of the stage
+type liveState struct {
+	bindings map[string]*liveBinding
+}
+
+// bindingsOf returns the live bindings that invoke the function
+func (l *liveState) bindingsOf(functionName string) []*liveBinding {
+	var bindings []*liveBinding
+	for _, binding := range l.bindings {
+		if name, _ := invokedFunction(binding.uri); name == functionName {
+			bindings = append(bindings, binding)
+		}
+	}
+	return bindings
+}
+
+// restUrl returns the invoke URL of a REST API resource on the stage
+func restUrl(restApiId string, stage string, resourcePath string) string {
+	return fmt.Sprintf("https://%s.execute-api.%s.amazonaws.com/%s%s", restApiId, viper.GetString("region"), stage, resourcePath)
+}
+
+// wsUrl returns the URL of a WebSocket API on the stage
+func wsUrl(wsApiId string, stage string) string {
+	return fmt.Sprintf("wss://%s.execute-api.%s.amazonaws.com/%s", wsApiId, viper.GetString("region"), stage)
+}
+
+// loadLiveState fetches the bindings of the REST and WebSocket APIs and the Cognito user pool of the stage
+func loadLiveState(stage string) (*liveState, error) {
+	l := &liveState{bindings: map[string]*liveBinding{}}
+	add := func(b *liveBinding) {
+		b.found = true
+		l.bindings[b.key()] = b
+	}
+
+	if restApiId := config.GetString("apiGateway.restApiId"); restApiId != "" {
+		gatewaySvc := apigateway.New(awsutils.GetSession(), awsutils.ServiceConfig("apigateway"))
+		if err := gatewaySvc.GetResourcesPages(
+			&apigateway.GetResourcesInput{
+				Limit:     aws.Int64(500),
+				RestApiId: &restApiId,
+				Embed:     aws.StringSlice([]string{"methods"}),
+			},
+			func(output *apigateway.GetResourcesOutput, lastPage bool) bool {
+				for _, resource := range output.Items {
+					for method, m := range resource.ResourceMethods {
+						b := &liveBinding{
+							kind:   BindingRest,
+							target: method + " " + aws.StringValue(resource.Path),
+							url:    restUrl(restApiId, stage, aws.StringValue(resource.Path)),
+						}
+						if m != nil && m.MethodIntegration != nil {
+							b.uri = aws.StringValue(m.MethodIntegration.Uri)
+						}
+						add(b)
+					}
+				}
+				return true
+			},
+		); err != nil {
+			return nil, err
+		}
+
+		input := &apigateway.GetAuthorizersInput{RestApiId: &restApiId, Limit: aws.Int64(500)}
+		for {
+			output, err := gatewaySvc.GetAuthorizers(input)
+			if err != nil {
+				return nil, err
+			}
+			for _, authorizer := range output.Items {
+				add(&liveBinding{
+					kind:   BindingAuthorizer,
+					target: aws.StringValue(authorizer.Id),
+					uri:    aws.StringValue(authorizer.AuthorizerUri),
+				})
+			}
+			if output.Position == nil {
+				break
+			}
+			input.Position = output.Position
+		}
+	}
+
+	if wsApiId := config.GetString("apiGateway.wsApiId"); wsApiId != "" {
+		wsGatewaySvc := apigatewayv2.New(awsutils.GetSession(), awsutils.ServiceConfig("apigatewayv2"))
+
+		integrationUris := map[string]string{}
+		integrationsInput := &apigatewayv2.GetIntegrationsInput{ApiId: &wsApiId}
+		for {
+			output, err := wsGatewaySvc.GetIntegrations(integrationsInput)
+			if err != nil {
+				return nil, err
+			}
+			for _, integration := range output.Items {
+				integrationUris[aws.StringValue(integration.IntegrationId)] = aws.StringValue(integration.IntegrationUri)
+			}
+			if output.NextToken == nil {
+				break
+			}
+			integrationsInput.NextToken = output.NextToken
+		}
+
+		routesInput := &apigatewayv2.GetRoutesInput{ApiId: &wsApiId}
+		for {
+			output, err := wsGatewaySvc.GetRoutes(routesInput)
+			if err != nil {
+				return nil, err
+			}
+			for _, route := range output.Items {
+				target := strings.TrimPrefix(aws.StringValue(route.Target), "integrations/")
+				add(&liveBinding{
+					kind:   BindingWs,
+					target: aws.StringValue(route.RouteKey),
+					url:    wsUrl(wsApiId, stage),
+					uri:    integrationUris[target],
+				})
+			}
+			if output.NextToken == nil {
+				break
+			}
+			routesInput.NextToken = output.NextToken
+		}
+
+		authorizersInput := &apigatewayv2.GetAuthorizersInput{ApiId: &wsApiId}
+		for {
+			output, err := wsGatewaySvc.GetAuthorizers(authorizersInput)
+			if err != nil {
+				return nil, err
+			}
+			for _, authorizer := range output.Items {
+				add(&liveBinding{
+					kind:   BindingWsAuthorizer,
+					target: aws.StringValue(authorizer.AuthorizerId),
+					uri:    aws.StringValue(authorizer.AuthorizerUri),
+				})
+			}
+			if output.NextToken == nil {
+				break
+			}
+			authorizersInput.NextToken = output.NextToken
+		}
+	}
+
+	if poolId, ok := config.GetStringMapString("cognito.userPools")[stage]; ok {
+		cognitoSvc := cognitoidentityprovider.New(awsutils.GetSession(), awsutils.ServiceConfig("cognitoidp"))
+		userPool, err := cognitoSvc.DescribeUserPool(&cognitoidentityprovider.DescribeUserPoolInput{
+			UserPoolId: &poolId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if lambdaConfig := userPool.UserPool.LambdaConfig; lambdaConfig != nil {
+			lambdaConfigElem := reflect.ValueOf(lambdaConfig).Elem()
+			for idx := 0; idx < lambdaConfigElem.NumField(); idx++ {
+				if value, ok := lambdaConfigElem.Field(idx).Interface().(*string); ok && value != nil {
+					add(&liveBinding{
+						kind:   BindingCognito,
+						target: lambdaConfigElem.Type().Field(idx).Name,
+						uri:    *value,
+					})
+				}
+			}
+		}
+	}
+
+	return l, nil
+}
+
+// configuredBindings returns the bindings of a function in the config and the qualifier they are expected to invoke it with
+func configuredBindings(function *viper.Viper, stage string) map[string]string {
+	bindings := map[string]string{}
+	if config.GetString("apiGateway.restApiId") != "" {
+		resources := function.GetStringSlice("api.resources")
+		if singleResource := function.GetString("api.resource"); singleResource != "" {
+			resources = append(resources, singleResource)
+		}
+		for _, resourceString := range resources {
+			if resource := strings.SplitN(resourceString, ":", 2); len(resource) == 2 {
+				target := strings.ToUpper(resource[0]) + " " + gateway.ResourcePath(resource[1])
+				bindings[BindingRest+" "+target] = gatewayQualifier
+			}
+		}
+		if authorizerId := function.GetString("api.authorizerId"); authorizerId != "" {
+			bindings[BindingAuthorizer+" "+authorizerId] = gatewayQualifier
+		}
+	}
+	if config.GetString("apiGateway.wsApiId") != "" {
+		wsResources := function.GetStringSlice("api.wsResources")
+		if singleWsResource := function.GetString("api.wsResource"); singleWsResource != "" {
+			wsResources = append(wsResources, singleWsResource)
+		}
+		for _, routeKey := range wsResources {
+			bindings[BindingWs+" "+routeKey] = gatewayQualifier
+		}
+		if wsAuthorizerId := function.GetString("api.wsAuthorizerId"); wsAuthorizerId != "" {
+			bindings[BindingWsAuthorizer+" "+wsAuthorizerId] = gatewayQualifier
+		}
+	}
+	if _, ok := config.GetStringMapString("cognito.userPools")[stage]; ok {
+		for _, trigger := range function.GetStringSlice("cognito.triggers") {
+			bindings[BindingCognito+" "+trigger] = stage
+		}
+	}
+	return bindings
+}
+
+// compareBindings returns the bindings of a function with the differences between the config and AWS
+func compareBindings(functionName string, configured map[string]string, live *liveState) []*Binding {
+	var bindings []*Binding
+	for key, expectedQualifier := range configured {
+		kindAndTarget := strings.SplitN(key, " ", 2)
+		b, found := live.bindings[key]
+		if !found {
+			b = &liveBinding{kind: kindAndTarget[0], target: kindAndTarget[1]}
+		}
+		binding := &Binding{
+			Kind:       b.kind,
+			Target:     b.target,
+			Url:        b.url,
+			Configured: true,
+		}
+		name, qualifier := invokedFunction(b.uri)
+		if name != "" {
+			binding.Live = name + ":" + qualifier
+		}
+		switch {
+		case !b.found:
+			binding.Drift = "not found"
+		case name == "":
+			binding.Drift = "not bound to a function"
+		case name != functionName || qualifier != expectedQualifier:
+			binding.Drift = "bound to " + binding.Live
+		}
+		bindings = append(bindings, binding)
+	}
+	for _, b := range live.bindingsOf(functionName) {
+		if _, ok := configured[b.key()]; ok {
+			continue
+		}
+		name, qualifier := invokedFunction(b.uri)
+		bindings = append(bindings, &Binding{
+			Kind:   b.kind,
+			Target: b.target,
+			Url:    b.url,
+			Live:   name + ":" + qualifier,
+			Drift:  "not in config",
+		})
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		if bindings[i].Kind != bindings[j].Kind {
+			return bindings[i].Kind < bindings[j].Kind
+		}
+		return bindings[i].Target < bindings[j].Target
+	})
+	return bindings
+}
+
+// latestVersion returns the highest published version of a function
+func latestVersion(lambdaSvc *lambda.Lambda, functionName string) (string, error) {
+	latest := 0
+	input := &lambda.ListVersionsByFunctionInput{FunctionName: &functionName}
+	for {
+		output, err := lambdaSvc.ListVersionsByFunction(input)
+		if err != nil {
+			return "", err
+		}
+		for _, version := range output.Versions {
+			if v, err := strconv.Atoi(aws.StringValue(version.Version)); err == nil && v > latest {
+				latest = v
+			}
+		}
+		if output.NextMarker == nil {
+			break
+		}
+		input.Marker = output.NextMarker
+	}
+	if latest == 0 {
+		return "", nil
+	}
+	return strconv.Itoa(latest), nil
+}
+
+// Functions returns the deployed state of the functions with the given names (or all functions if none are given)
+func Functions(names []string) ([]*FunctionStatus, error) {
+	functionConfigs := config.GetStringMapSub("serverless.functions", true)
+	if len(names) == 0 {
+		for name := range functionConfigs {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	stage := config.GetString("defaults.stage")
+	live, err := loadLiveState(stage)
+	if err != nil {
+		return nil, err
+	}
+
+	lambdaSvc := lambda.New(awsutils.GetSession(), awsutils.ServiceConfig("lambda"))
+
+	var statuses []*FunctionStatus
+	for _, name := range names {
+		function, ok := functionConfigs[name]
+		if !ok {
+			return nil, fmt.Errorf("function %s not found", name)
+		}
+		s := &FunctionStatus{
+			Name:         name,
+			FunctionName: functions.FunctionName(name, function),
+			Stage:        function.GetString("stage"),
+		}
+		statuses = append(statuses, s)
+		s.Bindings = compareBindings(s.FunctionName, configuredBindings(function, s.Stage), live)
+
+		qualifier := aws.String(s.Stage)
+		alias, err := lambdaSvc.GetAlias(&lambda.GetAliasInput{
+			FunctionName: &s.FunctionName,
+			Name:         &s.Stage,
+		})
+		if err == nil {
+			s.AliasVersion = aws.StringValue(alias.FunctionVersion)
+		} else if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == lambda.ErrCodeResourceNotFoundException {
+			qualifier = nil
+		} else {
+			return nil, err
+		}
+
+		configuration, err := lambdaSvc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+			FunctionName: &s.FunctionName,
+			Qualifier:    qualifier,
+		})
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == lambda.ErrCodeResourceNotFoundException {
+				continue
+			}
+			return nil, err
+		}
+		s.Deployed = true
+		s.CodeSize = aws.Int64Value(configuration.CodeSize)
+		s.LastModified = aws.StringValue(configuration.LastModified)
+		s.Runtime = aws.StringValue(configuration.Runtime)
+		s.MemorySize = aws.Int64Value(configuration.MemorySize)
+		s.Timeout = aws.Int64Value(configuration.Timeout)
+
+		if s.LatestVersion, err = latestVersion(lambdaSvc, s.FunctionName); err != nil {
+			return nil, err
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,94 @@
+package awsutils
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultMaxRetries = 8
+	retryBaseDelay    = 500 * time.Millisecond
+	retryMaxDelay     = 30 * time.Second
+)
+
+// conflictCodes are the error codes of calls that conflict with a resource. Concurrent modifications always pass,
+// while other conflicts are only transient if their message matches one of transientConflictMessages.
+var conflictCodes = map[string]bool{
+	"ResourceConflictException":       false, // lambda
+	"ConflictException":               false, // api gateway
+	"ConcurrentModificationException": true,  // iam, cognito
+}
+
+// transientConflictMessages are the lower-cased parts of the messages of conflicts with a change
+// still being applied to a resource, unlike permanent conflicts such as resources that already exist
+var transientConflictMessages = []string{
+	"update is in progress",                     // lambda
+	"currently in the following state: pending", // lambda, while a function is being created
+	"concurrent modification",                   // api gateway
+}
+
+// retryer retries throttled and conflicting calls, along with those the SDK retries by default,
+// with jittered exponential backoff
+type retryer struct {
+	maxRetries int
+}
+
+// Retryer returns the retry policy shared by every AWS client.
+// The number of retries can be set with `maxRetries`.
+func Retryer() request.Retryer {
+	maxRetries := defaultMaxRetries
+	if viper.IsSet("maxRetries") {
+		maxRetries = viper.GetInt("maxRetries")
+	}
+	return &retryer{maxRetries: maxRetries}
+}
+
+func (r *retryer) MaxRetries() int {
+	return r.maxRetries
+}
+
+func (r *retryer) ShouldRetry(req *request.Request) bool {
+	// handlers (e.g. of waiters or the metadata client) decide for their own requests
+	if req.Retryable != nil {
+		return *req.Retryable
+	}
+	if awsErr, ok := req.Error.(awserr.Error); ok && isTransientConflict(awsErr) {
+		return true
+	}
+	return req.IsErrorRetryable() || req.IsErrorThrottle()
+}
+
+// isTransientConflict checks if the error is a conflict that passes once a change to the resource has been applied
+func isTransientConflict(err awserr.Error) bool {
+	transient, isConflict := conflictCodes[err.Code()]
+	if !isConflict || transient {
+		return isConflict
+	}
+	message := strings.ToLower(err.Message())
+	for _, transientMessage := range transientConflictMessages {
+		if strings.Contains(message, transientMessage) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryRules returns a random delay between half and all of the exponential backoff delay of the attempt
+func (r *retryer) RetryRules(req *request.Request) time.Duration {
+	delay := retryMaxDelay
+	if req.RetryCount < 16 {
+		delay = time.Duration(math.Min(float64(retryBaseDelay)*math.Pow(2, float64(req.RetryCount)), float64(retryMaxDelay)))
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	if req.Error != nil {
+		logrus.Debugf("%s %s failed: %s. retrying in %s", req.ClientInfo.ServiceName, req.Operation.Name, req.Error, delay)
+	}
+	return delay
+}
@@ -0,0 +1,35 @@
+package awsutils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable *bool
+		want      bool
+	}{
+		{name: "no error", want: false},
+		{name: "validation error", err: awserr.New("ValidationException", "invalid name", nil), want: false},
+		{name: "throttled", err: awserr.New("ThrottlingException", "rate exceeded", nil), want: true},
+		{name: "concurrent modification", err: awserr.New("ConcurrentModificationException", "", nil), want: true},
+		{name: "update in progress", err: awserr.New("ResourceConflictException", "An update is in progress", nil), want: true},
+		{name: "already exists", err: awserr.New("ResourceConflictException", "Function already exist", nil), want: false},
+		{name: "marked not retryable", err: awserr.New("ThrottlingException", "rate exceeded", nil), retryable: aws.Bool(false), want: false},
+		{name: "marked retryable", err: errors.New("boom"), retryable: aws.Bool(true), want: true},
+	}
+	r := &retryer{maxRetries: defaultMaxRetries}
+	for _, test := range tests {
+		req := &request.Request{Error: test.err, Retryable: test.retryable}
+		if got := r.ShouldRetry(req); got != test.want {
+			t.Errorf("%s: ShouldRetry = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
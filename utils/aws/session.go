@@ -2,8 +2,10 @@ package awsutils
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/niranjan94/bifrost/utils"
 	"github.com/spf13/viper"
 	"sync"
 )
@@ -17,13 +19,29 @@ var (
 
 func GetSession() *session.Session {
 	sessionOnce.Do(func() {
-		awsSession = session.Must(session.NewSession(&aws.Config{
+		awsSession = session.Must(session.NewSession(request.WithRetryer(&aws.Config{
 			Region: aws.String(viper.GetString("region")),
-		}))
+		}, Retryer())))
+		// bind every call without an explicit context to the root context, so that it is cancelled on interrupt
+		awsSession.Handlers.Validate.PushFront(func(r *request.Request) {
+			if r.Context() == aws.BackgroundContext() {
+				r.SetContext(utils.Context())
+			}
+		})
 	})
 	return awsSession
 }
 
+// ServiceConfig returns the config overrides of an AWS service.
+// The endpoint is overridden with `endpoints.<service>` if set, e.g. to use a local stand-in of the service.
+func ServiceConfig(service string) *aws.Config {
+	cfg := &aws.Config{}
+	if endpoint := viper.GetString("endpoints." + service); endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+	}
+	return cfg
+}
+
 func GetIdentity() *sts.GetCallerIdentityOutput {
 	identityOnce.Do(func() {
 		svc := sts.New(GetSession())
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+var rootContext = context.Background()
+
+// interruptHandler is called by Must for errors caused by an interrupt
+var interruptHandler = func() {
+	os.Exit(130)
+}
+
+// SetContext sets the context long running operations are bound to
+func SetContext(ctx context.Context) {
+	rootContext = ctx
+}
+
+// Context returns the context long running operations are bound to.
+// It is cancelled when bifrost is interrupted.
+func Context() context.Context {
+	return rootContext
+}
+
+// SetInterruptHandler sets the function Must calls instead of panicking for errors caused by an interrupt.
+// The handler must exit.
+func SetInterruptHandler(handler func()) {
+	interruptHandler = handler
+}
+
+// IsInterrupted checks if the error was caused by cancelling the context long running operations are bound to
+func IsInterrupted(err error) bool {
+	if err == nil || rootContext.Err() == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == request.CanceledErrorCode
+}
+
+// Sleep pauses for the duration or until the context is done, in which case the context's error is returned
+func Sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// TarFromZip converts the zip archive at zipFile to a tar stream that can be copied to a container
+func TarFromZip(zipFile string) (io.Reader, error) {
+	zipReader, err := zip.OpenReader(zipFile)
+	if err != nil {
+		return nil, err
+	}
+	defer zipReader.Close()
+
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+
+	for _, file := range zipReader.File {
+		info := file.FileInfo()
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return nil, err
+		}
+		header.Name = strings.TrimPrefix(file.Name, "/")
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		contents, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(tarWriter, contents)
+		contents.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	return &buffer, nil
+}
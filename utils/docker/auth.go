@@ -0,0 +1,153 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/mitchellh/go-homedir"
+	"github.com/sirupsen/logrus"
+)
+
+// dockerHubRegistry is the key of Docker Hub credentials in the docker config
+const dockerHubRegistry = "https://index.docker.io/v1/"
+
+// dockerConfig is the part of the docker CLI's config.json that holds registry credentials
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// registryOf returns the registry an image is pulled from, e.g. ghcr.io for ghcr.io/org/image:tag.
+// Images without a registry are pulled from Docker Hub.
+func registryOf(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		if parts[0] == "docker.io" || parts[0] == "index.docker.io" {
+			return dockerHubRegistry
+		}
+		return parts[0]
+	}
+	return dockerHubRegistry
+}
+
+// configuredAuth returns the credentials of the registry among the given credentials by registry
+func configuredAuth(registries map[string]*types.AuthConfig, registry string) *types.AuthConfig {
+	for name, credentials := range registries {
+		if credentials == nil || registryOf(name+"/image") != registry {
+			continue
+		}
+		authConfig := *credentials
+		authConfig.ServerAddress = registry
+		return &authConfig
+	}
+	return nil
+}
+
+// loadDockerConfig reads the docker CLI's config from $DOCKER_CONFIG or ~/.docker
+func loadDockerConfig() (*dockerConfig, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return nil, err
+	}
+	cfg := &dockerConfig{}
+	if err := json.Unmarshal(contents, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// helperAuth returns the credentials of the registry from a docker credential helper
+func helperAuth(helper string, registry string) (*types.AuthConfig, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	var credentials struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &credentials); err != nil {
+		return nil, err
+	}
+	// helpers return identity tokens with <token> as the username
+	if credentials.Username == "<token>" {
+		return &types.AuthConfig{IdentityToken: credentials.Secret, ServerAddress: registry}, nil
+	}
+	return &types.AuthConfig{Username: credentials.Username, Password: credentials.Secret, ServerAddress: registry}, nil
+}
+
+// dockerConfigAuth returns the credentials of the registry from the docker CLI's config
+func dockerConfigAuth(registry string) *types.AuthConfig {
+	cfg, err := loadDockerConfig()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Warn("could not read the docker config: ", err)
+		}
+		return nil
+	}
+
+	helper := cfg.CredsStore
+	if registryHelper, ok := cfg.CredHelpers[registry]; ok {
+		helper = registryHelper
+	}
+	if helper != "" {
+		authConfig, err := helperAuth(helper, registry)
+		if err == nil {
+			return authConfig
+		}
+		logrus.Debugf("no credentials for %s from docker-credential-%s: %s", registry, helper, err)
+	}
+
+	auth, ok := cfg.Auths[registry]
+	if !ok {
+		return nil
+	}
+	authConfig := &types.AuthConfig{IdentityToken: auth.IdentityToken, ServerAddress: registry}
+	if decoded, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+		if credentials := strings.SplitN(string(decoded), ":", 2); len(credentials) == 2 {
+			authConfig.Username, authConfig.Password = credentials[0], credentials[1]
+		}
+	}
+	return authConfig
+}
+
+// RegistryAuth returns the encoded credentials of the registry of an image for ImagePullOptions.RegistryAuth.
+// The given credentials by registry take precedence over those of the docker CLI.
+// It returns an empty string if there are no credentials for the registry.
+func RegistryAuth(image string, registries map[string]*types.AuthConfig) (string, error) {
+	registry := registryOf(image)
+	authConfig := configuredAuth(registries, registry)
+	if authConfig == nil {
+		authConfig = dockerConfigAuth(registry)
+	}
+	if authConfig == nil {
+		return "", nil
+	}
+	encoded, err := json.Marshal(authConfig)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(encoded), nil
+}
@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestConfiguredAuth(t *testing.T) {
+	registries := map[string]*types.AuthConfig{
+		"registry.example.com": {Username: "ci", Password: "secret"},
+		"docker.io":            {IdentityToken: "token"},
+	}
+	tests := []struct {
+		registry string
+		want     *types.AuthConfig
+	}{
+		{
+			registry: "registry.example.com",
+			want:     &types.AuthConfig{Username: "ci", Password: "secret", ServerAddress: "registry.example.com"},
+		},
+		{
+			registry: dockerHubRegistry,
+			want:     &types.AuthConfig{IdentityToken: "token", ServerAddress: dockerHubRegistry},
+		},
+		{registry: "ghcr.io"},
+	}
+	for _, test := range tests {
+		if got := configuredAuth(registries, test.registry); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("configuredAuth(%q) = %+v, want %+v", test.registry, got, test.want)
+		}
+	}
+	if registries["registry.example.com"].ServerAddress != "" {
+		t.Error("configuredAuth modified the given credentials")
+	}
+}
@@ -5,11 +5,10 @@ import (
 	"fmt"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/pkg/namesgenerator"
+	"github.com/docker/go-connections/nat"
 	"github.com/sirupsen/logrus"
 	"io"
-	"io/ioutil"
 )
 
 // Container represents a docker container and the operations that can be run on it
@@ -22,50 +21,43 @@ func ContainerFromId(id string) *Container {
 	return &Container{id:id}
 }
 
-// StartContainer starts a container with the given image, entrypoint, cmd and host mounts
+// StartContainer creates a container from the given config, as CreateContainer does, and starts it
 // it wraps the container ID in a Container instance and returns it
-func StartContainer(image string, entrypoint []string, cmd []string, mounts []mount.Mount) (*Container, error) {
-	ctx := context.Background()
-	cli := GetClient()
-
-	logrus.Debug("looking up image ", image)
-
-	reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+func StartContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, pull PullOptions) (*Container, error) {
+	c, err := CreateContainer(ctx, config, hostConfig, pull)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.Start(ctx); err != nil {
+		_ = c.Remove(true)
+		return nil, err
+	}
+	return c, nil
+}
 
-	logrus.Debug("pulling image ", image)
-	io.Copy(ioutil.Discard, reader)
+// CreateContainer pulls the image of the given config according to the pull options and creates a container from it
+// without starting it
+// it wraps the container ID in a Container instance and returns it
+func CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, pull PullOptions) (*Container, error) {
+	cli := GetClient()
 
-	containerName := fmt.Sprintf("bifrost_%s", namesgenerator.GetRandomName(1))
+	logrus.Debug("looking up image ", config.Image)
 
-	resp, err := cli.ContainerCreate(
-		ctx,
-		&container.Config{
-			Image:      image,
-			Entrypoint: entrypoint,
-			Cmd:        cmd,
-		},
-		&container.HostConfig{
-			Mounts: mounts,
-		},
-		nil, containerName,
-	)
-	if err != nil {
+	if err := EnsureImage(ctx, config.Image, pull); err != nil {
 		return nil, err
 	}
 
-	logrus.Debugf("created container %s:%s", containerName, resp.ID[0:12])
+	containerName := fmt.Sprintf("bifrost_%s", namesgenerator.GetRandomName(1))
 
-	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+	resp, err := cli.ContainerCreate(ctx, config, hostConfig, nil, containerName)
+	if err != nil {
 		return nil, err
 	}
 
-	logrus.Debugf("started container %s:%s", containerName, resp.ID[0:12])
+	logrus.Debugf("created container %s:%s", containerName, resp.ID[0:12])
+	track(resp.ID)
 
 	return ContainerFromId(resp.ID), nil
-
 }
 
 // ID returns the current id
@@ -74,13 +66,13 @@ func (c *Container) ID() string {
 }
 
 // RunCommand executes the given command with arguments inside the container
-func (c *Container) RunCommand(command []string) (output string, err error)  {
-	return RunCommand(c.id, command)
+func (c *Container) RunCommand(ctx context.Context, command []string) (output string, err error)  {
+	return RunCommand(ctx, c.id, command)
 }
 
 // RunShellCommand executes the given command string within a /bin/bash shell inside the container
-func (c *Container) RunShellCommand(command string) (output string, err error)  {
-	return RunShellCommand(c.id, command)
+func (c *Container) RunShellCommand(ctx context.Context, command string) (output string, err error)  {
+	return RunShellCommand(ctx, c.id, command)
 }
 
 // Remove removes the container.
@@ -92,4 +84,39 @@ func (c *Container) Remove(force bool) (err error)  {
 // CopyTo copies the given contents to the container
 func (c *Container) CopyTo(path string, contents io.Reader) error {
 	return CopyToContainer(c.id, path, contents)
+}
+
+// Start starts the container
+func (c *Container) Start(ctx context.Context) error {
+	if err := GetClient().ContainerStart(ctx, c.id, types.ContainerStartOptions{}); err != nil {
+		return err
+	}
+	logrus.Debugf("started container %s", c.id[0:12])
+	return nil
+}
+
+// Wait waits for the container to exit and returns its exit code
+func (c *Container) Wait(ctx context.Context) (int64, error) {
+	return GetClient().ContainerWait(ctx, c.id)
+}
+
+// HostPort returns the host port that the given container port (eg. 9001/tcp) is published on
+func (c *Container) HostPort(containerPort string) (string, error) {
+	info, err := GetClient().ContainerInspect(context.Background(), c.id)
+	if err != nil {
+		return "", err
+	}
+	if info.NetworkSettings != nil {
+		for _, binding := range info.NetworkSettings.Ports[nat.Port(containerPort)] {
+			if binding.HostPort != "" {
+				return binding.HostPort, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("port %s of container %s is not published", containerPort, c.id[0:12])
+}
+
+// Logs returns the stdout and stderr output of the container
+func (c *Container) Logs() (stdout string, stderr string, err error) {
+	return ContainerLogs(c.id)
 }
\ No newline at end of file
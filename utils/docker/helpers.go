@@ -2,9 +2,11 @@ package docker
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"io"
@@ -12,12 +14,17 @@ import (
 
 // RemoveContainer removes the container with containerId.
 // force allows a running container to be forcefully removed.
+// It is not bound to the root context, so that containers can be removed after an interrupt.
 func RemoveContainer(containerId string, force bool) error {
 	docker := GetClient()
 	ctx := context.Background()
-	return docker.ContainerRemove(ctx, containerId, types.ContainerRemoveOptions{
+	if err := docker.ContainerRemove(ctx, containerId, types.ContainerRemoveOptions{
 		Force: force,
-	})
+	}); err != nil {
+		return err
+	}
+	untrack(containerId)
+	return nil
 }
 
 // CopyToContainer copies the given contents to the container with containerId
@@ -29,10 +36,29 @@ func CopyToContainer(containerId string, path string, contents io.Reader) error
 	})
 }
 
-// RunCommand executes the given command with arguments inside the container with containerId
-func RunCommand(containerId string, command []string) (string, error) {
+// ContainerLogs returns the stdout and stderr output of the container with containerId
+func ContainerLogs(containerId string) (string, string, error) {
 	docker := GetClient()
 	ctx := context.Background()
+	reader, err := docker.ContainerLogs(ctx, containerId, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	defer reader.Close()
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, reader); err != nil {
+		return stdout.String(), stderr.String(), err
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+// RunCommand executes the given command with arguments inside the container with containerId.
+// The command's output stops being read when ctx is done, in which case ctx's error is returned.
+func RunCommand(ctx context.Context, containerId string, command []string) (string, error) {
+	docker := GetClient()
 
 	execId, err := docker.ContainerExecCreate(ctx, containerId, types.ExecConfig{
 		Cmd:          command,
@@ -48,6 +74,19 @@ func RunCommand(containerId string, command []string) (string, error) {
 		return "", err
 	}
 
+	defer res.Close()
+
+	// closing the attached connection unblocks the scanner below
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			res.Close()
+		case <-done:
+		}
+	}()
+
 	var outputBytes []byte
 
 	scanner := bufio.NewScanner(res.Reader)
@@ -59,6 +98,10 @@ func RunCommand(containerId string, command []string) (string, error) {
 		outputBytes = append(outputBytes, []byte("\n")...)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return string(outputBytes), err
+	}
+
 	if err := scanner.Err(); err != nil {
 		return string(outputBytes), err
 	}
@@ -76,6 +119,6 @@ func RunCommand(containerId string, command []string) (string, error) {
 }
 
 // RunShellCommand executes the given command string within a /bin/bash shell inside the container with containerId
-func RunShellCommand(containerId string, command string) (string, error) {
-	return RunCommand(containerId, []string{"/bin/bash", "-c", command})
+func RunShellCommand(ctx context.Context, containerId string, command string) (string, error) {
+	return RunCommand(ctx, containerId, []string{"/bin/bash", "-c", command})
 }
@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// Pull policies decide when images are pulled before a container is created
+const (
+	PullAlways       = "always"
+	PullIfNotPresent = "if-not-present"
+	PullNever        = "never"
+)
+
+// pullProgressInterval is the minimum interval between progress messages of a layer being downloaded
+const pullProgressInterval = 3 * time.Second
+
+// pullMessage is a message of the progress stream of an image pull
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+// PullOptions decide when images are pulled and with which credentials
+type PullOptions struct {
+	// Policy is one of the pull policies. Images are always pulled if it is empty.
+	Policy string
+	// Registries are credentials by registry, which take precedence over those of the docker CLI
+	Registries map[string]*types.AuthConfig
+}
+
+// imagePresent checks if the image is present locally
+func imagePresent(ctx context.Context, image string) (bool, error) {
+	if _, _, err := GetClient().ImageInspectWithRaw(ctx, image); err != nil {
+		if client.IsErrImageNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// EnsureImage makes the image available locally according to the pull policy
+func EnsureImage(ctx context.Context, image string, options PullOptions) error {
+	policy := options.Policy
+	if policy != "" && policy != PullAlways {
+		present, err := imagePresent(ctx, image)
+		if err != nil {
+			return err
+		}
+		if present {
+			logrus.Debugf("image %s is present. not pulling it", image)
+			return nil
+		}
+		if policy == PullNever {
+			return fmt.Errorf("image %s is not present and the pull policy is %s", image, PullNever)
+		}
+	}
+	return PullImage(ctx, image, options.Registries)
+}
+
+// PullImage pulls the image with the credentials of its registry and logs the progress of the pull
+func PullImage(ctx context.Context, image string, registries map[string]*types.AuthConfig) error {
+	registryAuth, err := RegistryAuth(image, registries)
+	if err != nil {
+		return err
+	}
+
+	logrus.Info("pulling image ", image)
+	reader, err := GetClient().ImagePull(ctx, image, types.ImagePullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	lastStatus := map[string]string{}
+	lastProgress := map[string]time.Time{}
+	decoder := json.NewDecoder(reader)
+	for {
+		var message pullMessage
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+
+		switch {
+		case message.ID == "":
+			logrus.Info(message.Status)
+		case message.ProgressDetail.Total > 0:
+			if time.Since(lastProgress[message.ID]) < pullProgressInterval {
+				continue
+			}
+			lastProgress[message.ID] = time.Now()
+			logrus.Infof(
+				"%s: %s %.1f/%.1f MB", message.ID, message.Status,
+				float64(message.ProgressDetail.Current)/1e6, float64(message.ProgressDetail.Total)/1e6,
+			)
+		case lastStatus[message.ID] != message.Status:
+			logrus.Debugf("%s: %s", message.ID, message.Status)
+		}
+		lastStatus[message.ID] = message.Status
+	}
+}
@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	containersMutex sync.Mutex
+	containers      = map[string]bool{}
+)
+
+// track records a container created by bifrost, so that it can be removed by RemoveAll
+func track(containerId string) {
+	containersMutex.Lock()
+	defer containersMutex.Unlock()
+	containers[containerId] = true
+}
+
+// untrack forgets a removed container
+func untrack(containerId string) {
+	containersMutex.Lock()
+	defer containersMutex.Unlock()
+	delete(containers, containerId)
+}
+
+// RemoveAll forcefully removes every container created by bifrost that has not been removed yet
+func RemoveAll() {
+	containersMutex.Lock()
+	var ids []string
+	for id := range containers {
+		ids = append(ids, id)
+	}
+	containersMutex.Unlock()
+
+	for _, id := range ids {
+		logrus.Debugf("removing container %s", id[0:12])
+		if err := RemoveContainer(id, true); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
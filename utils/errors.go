@@ -1,7 +1,11 @@
 package utils
 
-// Must panics if the error is not nil
+// Must panics if the error is not nil.
+// Errors caused by an interrupt are handed to the interrupt handler instead.
 func Must(err error)  {
+	if IsInterrupted(err) {
+		interruptHandler()
+	}
 	if err != nil {
 		panic(err)
 	}